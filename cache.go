@@ -2,6 +2,7 @@ package tiercache
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"sync/atomic"
@@ -20,6 +21,9 @@ type MultiLevelCache[K comparable, V any] struct {
 
 	sync.RWMutex
 	built atomic.Bool
+
+	// computeMu serializes Compute calls when no level supports atomic compute
+	computeMu sync.Mutex
 }
 
 func NewMultiLevelCache[K comparable, V any](stores ...cacher.Interface[K, V]) *MultiLevelCache[K, V] {
@@ -72,40 +76,33 @@ func (c *MultiLevelCache[K, V]) MGet(ctx context.Context, keys []K, opts ...OptF
 	}
 	defer optionsPool.Put(o)
 
-	//allFoundItems := make(map[K]V)
-	//keysToFetch := keys
-	//
-	//for i := 0; i < len(c.stores); i++ {
-	//	if len(keysToFetch) == 0 {
-	//		break
-	//	}
-	//
-	//	store := c.stores[i]
-	//	foundItems, missingKeys, err := cacher.MGet(ctx, keysToFetch)
-	//
-	//	if err != nil {
-	//		// return nil, fmt.Errorf("cache store idx[%d] name[%s] MGet error: %s", i, cacher.Name(), err)
-	//		continue
-	//	}
-	//
-	//	if len(foundItems) > 0 {
-	//		for k, v := range foundItems {
-	//			allFoundItems[k] = v
-	//		}
-	//
-	//		// Back-population
-	//		if i > 0 {
-	//			for j := i - 1; j >= 0; j-- {
-	//				_ = c.stores[j].MSet(ctx, foundItems)
-	//			}
-	//		}
-	//	}
-	//
-	//	keysToFetch = missingKeys
-	//}
-
-	found, _, err := c.mGetRecursive(ctx, keys, 0, o)
-	return found, err
+	found, _, err := c.mGetRecursive(ctx, keys, 0, o, &MGetResult[K, V]{})
+	if err != nil {
+		return nil, err
+	}
+	return found, nil
+}
+
+// MGetDetailed works like MGet but keeps partial results when a level fails.
+// The returned result is never nil: it holds the found values, the missing keys, the keys that failed
+// with their error and every level error. The returned error is the one that stopped the read, if any.
+func (c *MultiLevelCache[K, V]) MGetDetailed(ctx context.Context, keys []K, opts ...OptFunc) (*MGetResult[K, V], error) {
+	res := &MGetResult[K, V]{}
+	if len(keys) == 0 {
+		res.Found = make(map[K]V)
+		return res, nil
+	}
+
+	o := defaultOpts()
+	for _, opt := range opts {
+		opt(o)
+	}
+	defer optionsPool.Put(o)
+
+	found, missing, err := c.mGetRecursive(ctx, keys, 0, o, res)
+	res.Found = found
+	res.Missing = missing
+	return res, err
 }
 
 func (c *MultiLevelCache[K, V]) Set(ctx context.Context, key K, value V, opts ...OptFunc) error {
@@ -160,7 +157,10 @@ func (c *MultiLevelCache[K, V]) MDel(ctx context.Context, keys []K, opts ...OptF
 	return nil
 }
 
-func (c *MultiLevelCache[K, V]) mGetRecursive(ctx context.Context, keys []K, levelIdx int, opts *cacheOpts) (map[K]V, []K, error) {
+// mGetRecursive queries the levels from levelIdx downwards and back-populates the found items.
+// When a level fails without fallback it returns what was found so far together with the error;
+// the keys that could not be resolved are recorded in res.
+func (c *MultiLevelCache[K, V]) mGetRecursive(ctx context.Context, keys []K, levelIdx int, opts *cacheOpts, res *MGetResult[K, V]) (map[K]V, []K, error) {
 	if levelIdx >= len(c.stores) {
 		return make(map[K]V), keys, nil // Return remaining keys as missing
 	}
@@ -171,26 +171,39 @@ func (c *MultiLevelCache[K, V]) mGetRecursive(ctx context.Context, keys []K, lev
 	mwCtx := cacher.NewContext(ctx, cacher.NewRunInfo(levelIdx+1))
 
 	currentStore := c.stores[levelIdx]
-	if opts.shouldSkipLayer != nil && opts.shouldSkipLayer(mwCtx, currentStore) {
-		return c.mGetRecursive(ctx, keys, levelIdx+1, opts)
+	if c.skipLayer(mwCtx, currentStore, opts) {
+		return c.mGetRecursive(ctx, keys, levelIdx+1, opts, res)
 	}
 
-	foundItems, missingKeys, err := currentStore.MGet(mwCtx, keys)
-	if err != nil {
-		// TODO: log error here
-		// Check if we should fallback to the next layer
-		shouldFallback := true // Default is to fallback
-		if opts.shouldFallbackOnError != nil {
-			shouldFallback = opts.shouldFallbackOnError(mwCtx, currentStore, err)
+	layerCtx, cancel := c.layerContext(mwCtx, currentStore, opts)
+	foundItems, missingKeys, err := currentStore.MGet(layerCtx, keys)
+	cancel()
+
+	var partialErr *cacher.PartialError[K]
+	if errors.As(err, &partialErr) {
+		// Only some keys failed: keep the answer for the others and treat the failed keys like a layer error
+		res.addLevelError(levelIdx+1, currentStore.Name(), err)
+		if c.shouldFallback(mwCtx, currentStore, err, opts) {
+			missingKeys = append(missingKeys, partialErr.Keys()...)
+		} else {
+			for k, kErr := range partialErr.Errors {
+				res.addKeyErrors([]K{k}, kErr)
+			}
 		}
+		err = nil
+	}
 
-		if !shouldFallback {
+	if err != nil {
+		res.addLevelError(levelIdx+1, currentStore.Name(), err)
+		// Check if we should fallback to the next layer
+		if !c.shouldFallback(mwCtx, currentStore, err, opts) {
 			// If configured not to fallback, return the error immediately
-			return nil, nil, err
+			res.addKeyErrors(keys, err)
+			return make(map[K]V), nil, err
 		}
 
 		// Fallback: current layer failed, treat all keys as missing and proceed to the next layer
-		return c.mGetRecursive(ctx, keys, levelIdx+1, opts)
+		return c.mGetRecursive(ctx, keys, levelIdx+1, opts, res)
 	}
 
 	if foundItems == nil {
@@ -199,23 +212,53 @@ func (c *MultiLevelCache[K, V]) mGetRecursive(ctx context.Context, keys []K, lev
 
 	if len(missingKeys) > 0 {
 		// Recursively query the next layer
-		deeperItems, stillMissing, gErr := c.mGetRecursive(ctx, missingKeys, levelIdx+1, opts)
-		if gErr != nil {
-			return nil, nil, gErr
-		}
+		deeperItems, stillMissing, gErr := c.mGetRecursive(ctx, missingKeys, levelIdx+1, opts, res)
 
-		// Back-populate data
+		// Back-populate data, including what a failed deeper layer found before it stopped
 		if len(deeperItems) > 0 {
 			for k, v := range deeperItems {
 				foundItems[k] = v
 			}
 			// Asynchronously or synchronously back-populate the current layer
-			_ = currentStore.MSet(mwCtx, deeperItems)
+			setCtx, cancel := c.layerContext(cacher.WithBackfill(mwCtx), currentStore, opts)
+			_ = currentStore.MSet(setCtx, deeperItems)
+			cancel()
 		}
 
 		// Update the final missing keys
 		missingKeys = stillMissing
+		if gErr != nil {
+			return foundItems, missingKeys, gErr
+		}
 	}
 
 	return foundItems, missingKeys, nil
 }
+
+// skipLayer reports whether the layer is left out of the current read
+func (c *MultiLevelCache[K, V]) skipLayer(ctx context.Context, store cacher.Interface[K, V], opts *cacheOpts) bool {
+	if opts.skipLoaders && cacher.IsLoader(store) {
+		return true
+	}
+	return opts.shouldSkipLayer != nil && opts.shouldSkipLayer(ctx, store)
+}
+
+// shouldFallback reports whether the keys of a failed layer should be queried from the next layer
+func (c *MultiLevelCache[K, V]) shouldFallback(ctx context.Context, store cacher.Interface[K, V], err error, opts *cacheOpts) bool {
+	if opts.shouldFallbackOnError == nil {
+		return true // Default is to fallback
+	}
+	return opts.shouldFallbackOnError(ctx, store, err)
+}
+
+// layerContext derives the context used to call a single layer, applying the layer timeout if one is configured.
+func (c *MultiLevelCache[K, V]) layerContext(ctx context.Context, store cacher.Interface[K, V], opts *cacheOpts) (context.Context, context.CancelFunc) {
+	if opts.layerTimeout == nil {
+		return ctx, func() {}
+	}
+	timeout := opts.layerTimeout(ctx, store)
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
@@ -4,11 +4,14 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/alicebob/miniredis/v2"
 	"github.com/mbeoliero/tiercache/cacher"
+	"github.com/mbeoliero/tiercache/datasource"
+	"github.com/mbeoliero/tiercache/localcache"
 	"github.com/mbeoliero/tiercache/middleware"
 	"github.com/mbeoliero/tiercache/rediscache"
 	"github.com/redis/go-redis/v9"
@@ -407,3 +410,283 @@ func TestRedisMapCache(t *testing.T) {
 
 	l2.MGet(ctx, []string{"k1", "k2", "k3", "k4", "k5", "k6"})
 }
+
+type slowCache struct {
+	LocalCache
+	delay time.Duration
+}
+
+func (s slowCache) MGet(ctx context.Context, keys []string) (map[string]string, []string, error) {
+	select {
+	case <-time.After(s.delay):
+		return s.LocalCache.MGet(ctx, keys)
+	case <-ctx.Done():
+		return nil, nil, ctx.Err()
+	}
+}
+
+func TestLayerTimeout(t *testing.T) {
+	l1 := slowCache{LocalCache: LocalCache{data: map[string]string{"key": "val1"}, name: "l1"}, delay: time.Second}
+	l2 := LocalCache{data: map[string]string{"key": "val2"}, name: "l2"}
+	mld := NewMultiLevelCache[string, string](l1, l2)
+
+	// L1 exceeds its budget and falls back to L2
+	start := time.Now()
+	v, err := mld.MGet(context.TODO(), []string{"key"}, WithLayerTimeout(LevelTimeouts(10*time.Millisecond)))
+	assert.Nil(t, err)
+	assert.Equal(t, "val2", v["key"])
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+
+	// Without fallback the timeout is returned as the layer error
+	_, err = mld.MGet(context.TODO(), []string{"key"},
+		WithLayerTimeout(LevelTimeouts(10*time.Millisecond)),
+		WithFallbackOnLayerError(func(ctx context.Context, info cacher.BaseInfo, err error) bool {
+			return false
+		}))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// A share of the caller's deadline
+	ctx, cancel := context.WithTimeout(context.TODO(), 200*time.Millisecond)
+	defer cancel()
+	start = time.Now()
+	v, err = mld.MGet(ctx, []string{"key"}, WithLayerTimeout(DeadlineShare(0.1)))
+	assert.Nil(t, err)
+	assert.Equal(t, "val2", v["key"])
+	assert.Less(t, time.Since(start), 100*time.Millisecond)
+}
+
+func TestMGetDetailed(t *testing.T) {
+	l1 := LocalCache{data: map[string]string{"a": "1"}, name: "l1"}
+	l2 := LocalCache{data: map[string]string{"b": "2"}, err: errors.New("l2 error"), name: "l2"}
+	l3 := LocalCache{data: map[string]string{"b": "3", "c": "3"}, name: "l3"}
+	mld := NewMultiLevelCache[string, string](l1, l2, l3)
+	noFallback := WithFallbackOnLayerError(func(ctx context.Context, info cacher.BaseInfo, err error) bool {
+		return false
+	})
+
+	// L2 fails without fallback: the L1 hit is kept, the other keys are reported per key
+	res, err := mld.MGetDetailed(context.TODO(), []string{"a", "b", "c"}, noFallback)
+	assert.NotNil(t, err)
+	assert.Equal(t, map[string]string{"a": "1"}, res.Found)
+	assert.Empty(t, res.Missing)
+	assert.Len(t, res.Errors, 2)
+	assert.Equal(t, "l2 error", res.Errors["b"].Error())
+	assert.Equal(t, []LevelError{{Level: 2, Name: "l2", Err: l2.err}}, res.LevelErrors)
+
+	// MGet keeps its all-or-nothing contract
+	v, err := mld.MGet(context.TODO(), []string{"a", "b"}, noFallback)
+	assert.NotNil(t, err)
+	assert.Nil(t, v)
+
+	// With fallback the level error is still reported
+	res, err = mld.MGetDetailed(context.TODO(), []string{"a", "b", "d"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"a": "1", "b": "3"}, res.Found)
+	assert.Equal(t, []string{"d"}, res.Missing)
+	assert.Empty(t, res.Errors)
+	assert.Len(t, res.LevelErrors, 1)
+}
+
+func TestDataSourceKeyErrors(t *testing.T) {
+	l1 := LocalCache{data: map[string]string{}, name: "l1"}
+	ds := datasource.NewDataSourceWithFetcher[string, string](func(ctx context.Context, key string) (string, error) {
+		switch key {
+		case "7":
+			return "", errors.New("bad row")
+		case "8":
+			return "", datasource.ErrNotFound
+		}
+		return "v" + key, nil
+	})
+	mld := NewMultiLevelCache[string, string](l1, ds)
+
+	// one bad row doesn't fail the request
+	v, err := mld.MGet(context.TODO(), []string{"6", "7", "8"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"6": "v6"}, v)
+	assert.Equal(t, map[string]string{"6": "v6"}, l1.data)
+
+	// the failed key is reported per key when it can't fall back
+	res, err := mld.MGetDetailed(context.TODO(), []string{"7", "8", "9"}, WithFallbackOnLayerError(func(ctx context.Context, info cacher.BaseInfo, err error) bool {
+		return false
+	}))
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"9": "v9"}, res.Found)
+	assert.Equal(t, []string{"8"}, res.Missing)
+	assert.Equal(t, "bad row", res.Errors["7"].Error())
+}
+
+func TestGetOrLoad(t *testing.T) {
+	l1 := LocalCache{data: map[string]string{}, name: "l1"}
+	l2 := LocalCache{data: map[string]string{"a": "cached"}, name: "l2"}
+	var dsCalls int
+	ds := datasource.NewDataSource(func(ctx context.Context, keys []string) (map[string]string, error) {
+		dsCalls++
+		return map[string]string{}, nil
+	})
+	mld := NewMultiLevelCache[string, string](l1, l2, ds)
+
+	var loaded []string
+	byEmail := func(ctx context.Context, keys []string) (map[string]string, error) {
+		loaded = append(loaded, keys...)
+		ret := make(map[string]string)
+		for _, k := range keys {
+			if k != "nobody" {
+				ret[k] = "loaded-" + k
+			}
+		}
+		return ret, nil
+	}
+
+	v, err := mld.MGetOrLoad(context.TODO(), []string{"a", "b", "nobody"}, byEmail)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"a": "cached", "b": "loaded-b"}, v)
+	// the loader only sees the keys the cache levels don't have, the DataSource level is skipped
+	assert.Equal(t, []string{"b", "nobody"}, loaded)
+	assert.Equal(t, 0, dsCalls)
+	// loaded values are back-populated
+	assert.Equal(t, "loaded-b", l1.data["b"])
+	assert.Equal(t, "loaded-b", l2.data["b"])
+
+	val, ok, err := mld.GetOrLoad(context.TODO(), "c", func(ctx context.Context, key string) (string, error) {
+		return "", datasource.ErrNotFound
+	})
+	assert.Nil(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, "", val)
+}
+
+func TestPeekAndExists(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer s.Close()
+	rdb := redis.NewClient(&redis.Options{
+		Addr: s.Addr(),
+	})
+	ctx := context.TODO()
+
+	l1 := localcache.NewLocalCache[string, string](time.Minute)
+	l2 := rediscache.NewRedisCache[string, string](rdb, time.Hour).SetPrefix("pre:").SetMiddleware(rediscache.MetricsMiddleware[string, string]("test")).ToStore()
+	var dsCalls int
+	ds := datasource.NewDataSource(func(ctx context.Context, keys []string) (map[string]string, error) {
+		dsCalls++
+		return map[string]string{"db": "db"}, nil
+	})
+	mld := NewMultiLevelCache[string, string](l1, l2, ds)
+
+	assert.Nil(t, l1.MSet(ctx, map[string]string{"a": "1"}))
+	assert.Nil(t, l2.MSet(ctx, map[string]string{"a": "1", "b": "2"}))
+
+	entries, err := mld.MPeek(ctx, []string{"a", "b", "db"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]Entry[string]{"a": {Value: "1", Level: 1}, "b": {Value: "2", Level: 2}}, entries)
+	// no back-population and no loader call
+	_, miss, _ := l1.MGet(ctx, []string{"b"})
+	assert.Equal(t, []string{"b"}, miss)
+	assert.Equal(t, 0, dsCalls)
+
+	levels, err := mld.MExists(ctx, []string{"a", "b", "db"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]int{"a": 1, "b": 2}, levels)
+	assert.Equal(t, 0, dsCalls)
+
+	level, found, err := mld.Exists(ctx, "b", WithShouldSkipLayer(func(ctx context.Context, info cacher.BaseInfo) bool {
+		return cacher.GetRunInfo(ctx).Level() == 2
+	}))
+	assert.Nil(t, err)
+	assert.False(t, found)
+	assert.Equal(t, 0, level)
+}
+
+func TestCompute(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer s.Close()
+	rdb := redis.NewClient(&redis.Options{
+		Addr: s.Addr(),
+	})
+	ctx := context.TODO()
+
+	l1 := localcache.NewLocalCache[string, int](time.Minute)
+	l2 := rediscache.NewRedisCache[string, int](rdb, time.Hour).SetPrefix("pre:").ToStore()
+	mld := NewMultiLevelCache[string, int](l1, l2)
+
+	incr := func(old int, found bool) (int, cacher.Action) {
+		return old + 1, cacher.ActionSet
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _, err := mld.Compute(ctx, "counter", incr)
+			assert.Nil(t, err)
+		}()
+	}
+	wg.Wait()
+
+	v, _, err := mld.Get(ctx, "counter")
+	assert.Nil(t, err)
+	assert.Equal(t, 20, v)
+	found, _, _ := l1.MGet(ctx, []string{"counter"})
+	assert.Equal(t, 20, found["counter"])
+
+	v, action, err := mld.Compute(ctx, "counter", func(old int, found bool) (int, cacher.Action) {
+		return 0, cacher.ActionDelete
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, cacher.ActionDelete, action)
+	_, ok, _ := mld.Peek(ctx, "counter")
+	assert.False(t, ok)
+
+	// without a Computer level the compute is serialized in process
+	local := NewMultiLevelCache[string, int](localcache.NewLocalCache[string, int](time.Minute))
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _, _ = local.Compute(ctx, "counter", incr)
+		}()
+	}
+	wg.Wait()
+	v, _, err = local.Get(ctx, "counter")
+	assert.Nil(t, err)
+	assert.Equal(t, 20, v)
+}
+
+func TestTouch(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer s.Close()
+	rdb := redis.NewClient(&redis.Options{
+		Addr: s.Addr(),
+	})
+	ctx := context.TODO()
+
+	l1 := localcache.NewLocalCache[string, string](time.Minute)
+	l2 := rediscache.NewRedisCache[string, string](rdb, time.Hour).SetPrefix("pre:").SetMiddleware(rediscache.MetricsMiddleware[string, string]("test")).ToStore()
+	ds := datasource.NewDataSource(func(ctx context.Context, keys []string) (map[string]string, error) {
+		t.Fatal("Touch must not call loaders")
+		return nil, nil
+	})
+	mld := NewMultiLevelCache[string, string](l1, l2, ds)
+
+	assert.Nil(t, mld.MSet(ctx, map[string]string{"a": "1"}))
+	s.FastForward(30 * time.Minute)
+	assert.Nil(t, mld.Touch(ctx, []string{"a", "missing"}))
+	assert.Equal(t, time.Hour, s.TTL("pre:a"))
+	assert.False(t, s.Exists("pre:missing"))
+
+	// skipped levels are not touched
+	s.FastForward(30 * time.Minute)
+	assert.Nil(t, mld.Touch(ctx, []string{"a"}, WithShouldSkipLayer(func(ctx context.Context, info cacher.BaseInfo) bool {
+		return cacher.GetRunInfo(ctx).Level() == 2
+	})))
+	assert.Equal(t, 30*time.Minute, s.TTL("pre:a"))
+}
@@ -28,3 +28,17 @@ func GetRunInfo(ctx context.Context) RunInfo {
 	}
 	return &runInfo{}
 }
+
+type backfillKey struct{}
+
+// WithBackfill marks the writes made with ctx as back-population of values read from a lower level,
+// as opposed to writes of new data. Stores can use it to avoid replacing newer data with what was read.
+func WithBackfill(ctx context.Context) context.Context {
+	return context.WithValue(ctx, backfillKey{}, true)
+}
+
+// IsBackfill reports whether ctx was marked by WithBackfill
+func IsBackfill(ctx context.Context) bool {
+	backfill, _ := ctx.Value(backfillKey{}).(bool)
+	return backfill
+}
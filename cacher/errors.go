@@ -0,0 +1,46 @@
+package cacher
+
+import (
+	"fmt"
+
+	"github.com/mbeoliero/tiercache/internal/convert"
+)
+
+// PartialError is returned by a store's MGet when only some of the keys failed.
+// The found and missing keys are returned as usual; the failed keys are in neither of them
+// and are reported here with their own error.
+type PartialError[K comparable] struct {
+	Errors map[K]error
+}
+
+func NewPartialError[K comparable](errs map[K]error) *PartialError[K] {
+	return &PartialError[K]{Errors: errs}
+}
+
+// Error reports the number of failed keys and the error of the key whose string form sorts first,
+// so the message doesn't depend on map order
+func (e *PartialError[K]) Error() string {
+	if len(e.Errors) == 0 {
+		return "0 keys failed"
+	}
+	var (
+		first    string
+		firstErr error
+		seen     bool
+	)
+	for k, err := range e.Errors {
+		if s := convert.ToString(k); !seen || s < first {
+			first, firstErr, seen = s, err, true
+		}
+	}
+	return fmt.Sprintf("%d keys failed, key[%s]: %s", len(e.Errors), first, firstErr)
+}
+
+// Keys returns the keys that failed
+func (e *PartialError[K]) Keys() []K {
+	keys := make([]K, 0, len(e.Errors))
+	for k := range e.Errors {
+		keys = append(keys, k)
+	}
+	return keys
+}
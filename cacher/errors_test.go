@@ -0,0 +1,16 @@
+package cacher
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPartialErrorMessage(t *testing.T) {
+	err := NewPartialError(map[string]error{"b": errors.New("timeout"), "a": errors.New("down"), "c": errors.New("refused")})
+	for range 10 {
+		assert.EqualError(t, err, "3 keys failed, key[a]: down")
+	}
+	assert.EqualError(t, NewPartialError(map[int]error{}), "0 keys failed")
+}
@@ -18,3 +18,70 @@ type RunInfo interface {
 	// Level returns the current cache level index, starting from 1 (e.g. 1, 2, 3...).
 	Level() int
 }
+
+// Loader is implemented by stores that load data from the system of record instead of caching it,
+// such as datasource.DataSource.
+type Loader interface {
+	IsLoader() bool
+}
+
+// Unwrapper is implemented by middleware wrappers to expose the store they wrap.
+type Unwrapper[K comparable, V any] interface {
+	Unwrap() Interface[K, V]
+}
+
+// As walks the chain of middleware wrappers of store and returns the first one implementing T.
+//
+//	loader, ok := cacher.As[cacher.Loader](store)
+func As[T any, K comparable, V any](store Interface[K, V]) (T, bool) {
+	for store != nil {
+		if t, ok := store.(T); ok {
+			return t, true
+		}
+		u, ok := store.(Unwrapper[K, V])
+		if !ok {
+			break
+		}
+		store = u.Unwrap()
+	}
+	var zero T
+	return zero, false
+}
+
+// IsLoader reports whether store, or a store wrapped by it, is a Loader.
+func IsLoader[K comparable, V any](store Interface[K, V]) bool {
+	l, ok := As[Loader](store)
+	return ok && l.IsLoader()
+}
+
+// Exister is implemented by stores that can check for keys without reading their values.
+type Exister[K comparable] interface {
+	MExists(ctx context.Context, keys []K) (map[K]bool, error)
+}
+
+// Toucher is implemented by stores that can extend the expiration of keys without rewriting them.
+type Toucher[K comparable] interface {
+	MTouch(ctx context.Context, keys []K) error
+}
+
+// Action tells Compute what to do with the value returned by a ComputeFunc.
+type Action int
+
+const (
+	// ActionKeep leaves the stored value untouched
+	ActionKeep Action = iota
+	// ActionSet stores the returned value
+	ActionSet
+	// ActionDelete deletes the key
+	ActionDelete
+)
+
+// ComputeFunc derives the new value of a key from the old one. found is false when the key doesn't exist.
+// It may be called several times when a concurrent write forces Compute to retry, so it must not have side effects.
+type ComputeFunc[V any] func(old V, found bool) (V, Action)
+
+// Computer is implemented by stores that can run an atomic read-modify-write on a key.
+// Compute returns the value the key holds afterwards and the action that was applied.
+type Computer[K comparable, V any] interface {
+	Compute(ctx context.Context, key K, fn ComputeFunc[V]) (V, Action, error)
+}
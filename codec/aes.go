@@ -0,0 +1,145 @@
+package codec
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+// ErrUnknownKeyID is returned for values sealed with a key that is not in the keyring
+var ErrUnknownKeyID = errors.New("codec: unknown encryption key id")
+
+// ErrKeyRequired is returned by Marshal and Unmarshal of an AESCodec that binds values to their cache key,
+// e.g. because a codec wrapping it doesn't pass the key on
+var ErrKeyRequired = errors.New("codec: key binding requires MarshalKey and UnmarshalKey")
+
+// aesFormat is the first header byte of values sealed by AESCodec
+const aesFormat byte = 0x01
+
+// Keyring holds the AES keys of an AESCodec by id. The active key seals new values, the other keys
+// are only used to open values sealed before a rotation.
+type Keyring struct {
+	active byte
+	aeads  map[byte]cipher.AEAD
+}
+
+// NewKeyring builds a keyring from AES-128, AES-192 or AES-256 keys. active must be one of the ids.
+func NewKeyring(active byte, keys map[byte][]byte) (*Keyring, error) {
+	if _, ok := keys[active]; !ok {
+		return nil, fmt.Errorf("codec: active key %d is not in the keyring", active)
+	}
+	ring := &Keyring{active: active, aeads: make(map[byte]cipher.AEAD, len(keys))}
+	for id, key := range keys {
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("codec: key %d: %w", id, err)
+		}
+		if ring.aeads[id], err = cipher.NewGCM(block); err != nil {
+			return nil, err
+		}
+	}
+	return ring, nil
+}
+
+// AESCodec encrypts the output of a codec with AES-GCM. A sealed value is a format byte, the id of the key
+// that sealed it, a random nonce and the ciphertext; the header is authenticated along with the ciphertext.
+// To rotate keys, add a new key as active and keep the old ones until their values have expired.
+// Values that are not encrypted, were tampered with or were sealed for another cache key fail with ErrDiscard,
+// so RedisCache deletes them; values sealed with a key missing from the keyring fail with ErrUnknownKeyID.
+//
+// AESCodec should be the outermost codec: compress before encrypting, since ciphertext doesn't compress.
+type AESCodec[T any] struct {
+	codec   Codec[T]
+	ring    atomic.Pointer[Keyring]
+	bindKey bool
+}
+
+// NewAESCodec encrypts the output of codec with the keys of ring.
+func NewAESCodec[T any](codec Codec[T], ring *Keyring) *AESCodec[T] {
+	c := &AESCodec[T]{codec: codec}
+	c.ring.Store(ring)
+	return c
+}
+
+// SetKeyring replaces the keyring, e.g. to rotate keys without recreating the cache
+func (c *AESCodec[T]) SetKeyring(ring *Keyring) *AESCodec[T] {
+	c.ring.Store(ring)
+	return c
+}
+
+// SetBindKey binds values to their cache key as associated data, so a value copied to another key
+// fails to open. Only MarshalKey and UnmarshalKey, which RedisCache uses, know the key; Marshal and Unmarshal
+// then fail with ErrKeyRequired.
+func (c *AESCodec[T]) SetBindKey(bind bool) *AESCodec[T] {
+	c.bindKey = bind
+	return c
+}
+
+func (c *AESCodec[T]) Marshal(v T) ([]byte, error) {
+	if c.bindKey {
+		return nil, ErrKeyRequired
+	}
+	return c.seal("", false, v)
+}
+
+func (c *AESCodec[T]) Unmarshal(data []byte, v *T) error {
+	if c.bindKey {
+		return ErrKeyRequired
+	}
+	return c.open("", false, data, v)
+}
+
+func (c *AESCodec[T]) MarshalKey(key string, v T) ([]byte, error) {
+	return c.seal(key, true, v)
+}
+
+func (c *AESCodec[T]) UnmarshalKey(key string, data []byte, v *T) error {
+	return c.open(key, true, data, v)
+}
+
+func (c *AESCodec[T]) seal(key string, keyed bool, v T) ([]byte, error) {
+	plain, err := marshalInner(c.codec, key, keyed, v)
+	if err != nil {
+		return nil, err
+	}
+	ring := c.ring.Load()
+	aead := ring.aeads[ring.active]
+
+	out := make([]byte, 2+aead.NonceSize(), 2+aead.NonceSize()+len(plain)+aead.Overhead())
+	out[0], out[1] = aesFormat, ring.active
+	nonce := out[2:]
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(out, nonce, plain, c.additionalData(out[:2], key)), nil
+}
+
+func (c *AESCodec[T]) open(key string, keyed bool, data []byte, v *T) error {
+	if len(data) < 2 || data[0] != aesFormat {
+		return fmt.Errorf("%w: value is not encrypted", ErrDiscard)
+	}
+	aead, ok := c.ring.Load().aeads[data[1]]
+	if !ok {
+		return fmt.Errorf("%w: %d", ErrUnknownKeyID, data[1])
+	}
+	if len(data) < 2+aead.NonceSize()+aead.Overhead() {
+		return fmt.Errorf("%w: encrypted value too short", ErrDiscard)
+	}
+	nonce, sealed := data[2:2+aead.NonceSize()], data[2+aead.NonceSize():]
+	plain, err := aead.Open(nil, nonce, sealed, c.additionalData(data[:2], key))
+	if err != nil {
+		// tampered with, or sealed for another key
+		return fmt.Errorf("%w: %w", ErrDiscard, err)
+	}
+	return unmarshalInner(c.codec, key, keyed, plain, v)
+}
+
+func (c *AESCodec[T]) additionalData(header []byte, key string) []byte {
+	if !c.bindKey {
+		return header
+	}
+	return append(append([]byte{}, header...), key...)
+}
@@ -0,0 +1,83 @@
+package codec
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testKeyring(t *testing.T, active byte, ids ...byte) *Keyring {
+	keys := make(map[byte][]byte, len(ids))
+	for _, id := range ids {
+		keys[id] = bytes.Repeat([]byte{id}, 32)
+	}
+	ring, err := NewKeyring(active, keys)
+	assert.Nil(t, err)
+	return ring
+}
+
+func TestAESCodec(t *testing.T) {
+	c := NewAESCodec[string](&JsonCodec[string]{}, testKeyring(t, 1, 1))
+	data, err := c.Marshal("secret")
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{aesFormat, 1}, data[:2])
+	assert.False(t, bytes.Contains(data, []byte("secret")))
+	var v string
+	assert.Nil(t, c.Unmarshal(data, &v))
+	assert.Equal(t, "secret", v)
+
+	// nonces are random
+	again, _ := c.Marshal("secret")
+	assert.NotEqual(t, data, again)
+
+	// old keys still open values after a rotation, new values use the active key
+	c.SetKeyring(testKeyring(t, 2, 1, 2))
+	assert.Nil(t, c.Unmarshal(data, &v))
+	rotated, _ := c.Marshal("secret")
+	assert.Equal(t, byte(2), rotated[1])
+
+	c.SetKeyring(testKeyring(t, 2, 2))
+	assert.ErrorIs(t, c.Unmarshal(data, &v), ErrUnknownKeyID)
+	assert.NotErrorIs(t, c.Unmarshal(data, &v), ErrDiscard)
+
+	tampered := append([]byte{}, rotated...)
+	tampered[len(tampered)-1] ^= 1
+	assert.ErrorIs(t, c.Unmarshal(tampered, &v), ErrDiscard)
+	assert.ErrorIs(t, c.Unmarshal([]byte(`"plain"`), &v), ErrDiscard)
+
+	_, err = NewKeyring(3, map[byte][]byte{1: make([]byte, 32)})
+	assert.NotNil(t, err)
+	_, err = NewKeyring(1, map[byte][]byte{1: make([]byte, 7)})
+	assert.NotNil(t, err)
+}
+
+func TestAESCodecBindKey(t *testing.T) {
+	c := NewAESCodec[string](&JsonCodec[string]{}, testKeyring(t, 1, 1)).SetBindKey(true)
+	data, err := c.MarshalKey("user:1", "secret")
+	assert.Nil(t, err)
+	var v string
+	assert.Nil(t, c.UnmarshalKey("user:1", data, &v))
+	assert.Equal(t, "secret", v)
+	assert.ErrorIs(t, c.UnmarshalKey("user:2", data, &v), ErrDiscard)
+
+	// without the key nothing is bound, so the plain methods refuse to work
+	_, err = c.Marshal("secret")
+	assert.ErrorIs(t, err, ErrKeyRequired)
+	assert.ErrorIs(t, c.Unmarshal(data, &v), ErrKeyRequired)
+
+	// wrapping codecs pass the key on
+	for name, wrapped := range map[string]KeyedCodec[string]{
+		"checksum": NewChecksumCodec[string](c, CRC32),
+		"compress": NewCompressCodec[string](c, Gzip, 1),
+		"schema":   NewSchemaCodec[string](c, 1),
+	} {
+		data, err := wrapped.MarshalKey("user:1", "secret")
+		assert.Nil(t, err, name)
+		assert.Nil(t, wrapped.UnmarshalKey("user:1", data, &v), name)
+		assert.Equal(t, "secret", v, name)
+		assert.ErrorIs(t, wrapped.UnmarshalKey("user:2", data, &v), ErrDiscard, name)
+		_, err = wrapped.Marshal("secret")
+		assert.ErrorIs(t, err, ErrKeyRequired, name)
+	}
+}
@@ -0,0 +1,110 @@
+package codec
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// ErrChecksum is returned for values whose checksum doesn't match. It is wrapped with ErrDiscard,
+// so RedisCache deletes such values.
+var ErrChecksum = errors.New("codec: checksum mismatch")
+
+// Checksum is the algorithm used by ChecksumCodec
+type Checksum byte
+
+// The values double as the byte after checksumMagic in checksummed data
+const (
+	// CRC32 is CRC-32C, 4 bytes per value
+	CRC32 Checksum = 0x10
+	// XXHash is 64-bit xxHash, 8 bytes per value and faster on large values
+	XXHash Checksum = 0x11
+)
+
+// checksumMagic starts checksummed data. None of the codecs of this package start a value with these bytes:
+// to CompactCodec 0xff is a complete value, to gob "\xffc" a non-canonical length, and 0xff never starts JSON.
+const checksumMagic = "\xffck"
+
+var crc32Table = crc32.MakeTable(crc32.Castagnoli)
+
+// ChecksumCodec wraps a codec and stores a checksum with each value, as a header of a 3-byte magic and a byte
+// naming the algorithm, followed by the checksum of the encoded value. Values failing the check return ErrChecksum
+// instead of being decoded into garbage. Values without the header, e.g. written before the ChecksumCodec was
+// introduced, are decoded unchecked; a wrapped codec whose output could start with the magic would make that
+// ambiguous. Both algorithms are always verified, so the algorithm can be changed on a cache in use.
+type ChecksumCodec[T any] struct {
+	codec Codec[T]
+	algo  Checksum
+}
+
+// NewChecksumCodec checksums the output of codec with algo.
+func NewChecksumCodec[T any](codec Codec[T], algo Checksum) *ChecksumCodec[T] {
+	return &ChecksumCodec[T]{codec: codec, algo: algo}
+}
+
+func (c *ChecksumCodec[T]) Marshal(v T) ([]byte, error) {
+	return c.marshal("", false, v)
+}
+
+func (c *ChecksumCodec[T]) MarshalKey(key string, v T) ([]byte, error) {
+	return c.marshal(key, true, v)
+}
+
+func (c *ChecksumCodec[T]) Unmarshal(data []byte, v *T) error {
+	return c.unmarshal("", false, data, v)
+}
+
+func (c *ChecksumCodec[T]) UnmarshalKey(key string, data []byte, v *T) error {
+	return c.unmarshal(key, true, data, v)
+}
+
+func (c *ChecksumCodec[T]) marshal(key string, keyed bool, v T) ([]byte, error) {
+	data, err := marshalInner(c.codec, key, keyed, v)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, 0, len(checksumMagic)+1+8+len(data))
+	out = append(append(out, checksumMagic...), byte(c.algo))
+	switch c.algo {
+	case CRC32:
+		out = binary.BigEndian.AppendUint32(out, crc32.Checksum(data, crc32Table))
+	case XXHash:
+		out = binary.BigEndian.AppendUint64(out, xxhash.Sum64(data))
+	default:
+		return nil, fmt.Errorf("codec: unknown checksum %d", c.algo)
+	}
+	return append(out, data...), nil
+}
+
+func (c *ChecksumCodec[T]) unmarshal(key string, keyed bool, data []byte, v *T) error {
+	header := len(checksumMagic) + 1
+	if len(data) < header || string(data[:len(checksumMagic)]) != checksumMagic {
+		return unmarshalInner(c.codec, key, keyed, data, v)
+	}
+	var ok bool
+	algo, data := Checksum(data[header-1]), data[header:]
+	switch algo {
+	case CRC32:
+		if len(data) < 4 {
+			return fmt.Errorf("%w: %w: value too short", ErrDiscard, ErrChecksum)
+		}
+		ok = binary.BigEndian.Uint32(data) == crc32.Checksum(data[4:], crc32Table)
+		data = data[4:]
+	case XXHash:
+		if len(data) < 8 {
+			return fmt.Errorf("%w: %w: value too short", ErrDiscard, ErrChecksum)
+		}
+		ok = binary.BigEndian.Uint64(data) == xxhash.Sum64(data[8:])
+		data = data[8:]
+	default:
+		// may be written by a newer version, so it is not discarded
+		return fmt.Errorf("codec: unknown checksum %d", algo)
+	}
+	if !ok {
+		return fmt.Errorf("%w: %w", ErrDiscard, ErrChecksum)
+	}
+	return unmarshalInner(c.codec, key, keyed, data, v)
+}
@@ -0,0 +1,52 @@
+package codec
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChecksumCodec(t *testing.T) {
+	for _, algo := range []Checksum{CRC32, XXHash} {
+		c := NewChecksumCodec[payload](&JsonCodec[payload]{}, algo)
+		v := payload{ID: 1, Name: "a", Tags: []string{"x"}}
+		data, err := c.Marshal(v)
+		assert.Nil(t, err)
+		assert.Equal(t, append([]byte(checksumMagic), byte(algo)), data[:4])
+		var got payload
+		assert.Nil(t, c.Unmarshal(data, &got))
+		assert.Equal(t, v, got)
+
+		// any flipped bit is detected
+		for i := 4; i < len(data); i += 7 {
+			corrupt := append([]byte{}, data...)
+			corrupt[i] ^= 0x04
+			err := c.Unmarshal(corrupt, &got)
+			assert.ErrorIs(t, err, ErrChecksum)
+			assert.ErrorIs(t, err, ErrDiscard)
+		}
+		assert.ErrorIs(t, c.Unmarshal(data[:6], &got), ErrChecksum)
+	}
+
+	// values from before the checksum are read unchecked, whatever the configured algorithm
+	c := NewChecksumCodec[string](&JsonCodec[string]{}, CRC32)
+	var s string
+	assert.Nil(t, c.Unmarshal([]byte(`"legacy"`), &s))
+	assert.Equal(t, "legacy", s)
+	data, _ := NewChecksumCodec[string](&JsonCodec[string]{}, XXHash).Marshal("other")
+	assert.Nil(t, c.Unmarshal(data, &s))
+	assert.Equal(t, "other", s)
+
+	// legacy values starting with the bytes of an algorithm are not mistaken for checksummed ones
+	n := NewChecksumCodec[int](&CompactCodec[int]{}, CRC32)
+	for _, want := range []int{16, 17, -1} {
+		legacy, _ := (&CompactCodec[int]{}).Marshal(want)
+		var got int
+		assert.Nil(t, n.Unmarshal(legacy, &got))
+		assert.Equal(t, want, got)
+	}
+	var got int
+	err := n.Unmarshal([]byte(checksumMagic+"\x7f"), &got)
+	assert.NotNil(t, err)
+	assert.NotErrorIs(t, err, ErrDiscard)
+}
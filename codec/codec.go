@@ -7,6 +7,32 @@ type Codec[T any] interface {
 	Unmarshal([]byte, *T) error
 }
 
+// KeyedCodec is implemented by codecs that need the cache key of a value, e.g. to bind ciphertext to it.
+// RedisCache calls MarshalKey and UnmarshalKey instead of Marshal and Unmarshal if its codec implements it.
+// The wrapping codecs of this package implement it too and pass the key on to the codec they wrap.
+type KeyedCodec[T any] interface {
+	Codec[T]
+	MarshalKey(key string, v T) ([]byte, error)
+	UnmarshalKey(key string, data []byte, v *T) error
+}
+
+// marshalInner encodes v with the codec wrapped by another codec. If the wrapping codec was given
+// the cache key, keyed is set and the key is passed on to a KeyedCodec.
+func marshalInner[T any](c Codec[T], key string, keyed bool, v T) ([]byte, error) {
+	if kc, ok := c.(KeyedCodec[T]); ok && keyed {
+		return kc.MarshalKey(key, v)
+	}
+	return c.Marshal(v)
+}
+
+// unmarshalInner is the decoding counterpart of marshalInner
+func unmarshalInner[T any](c Codec[T], key string, keyed bool, data []byte, v *T) error {
+	if kc, ok := c.(KeyedCodec[T]); ok && keyed {
+		return kc.UnmarshalKey(key, data, v)
+	}
+	return c.Unmarshal(data, v)
+}
+
 type JsonCodec[T any] struct{}
 
 func (c *JsonCodec[T]) Marshal(v T) ([]byte, error) {
@@ -0,0 +1,656 @@
+package codec
+
+import (
+	"encoding"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sync"
+)
+
+// ErrCompactData is returned when data can't be decoded by CompactCodec
+var ErrCompactData = errors.New("codec: invalid compact data")
+
+// CompactCodec is a dependency-free binary codec using the MessagePack format for primitives, strings,
+// byte slices, slices, arrays, maps, pointers and interfaces. Structs are encoded as an array of their
+// exported fields in declaration order, without field names; fields can be appended to a struct later,
+// because missing trailing fields decode as zero values and extra ones are skipped, but fields must not be
+// removed or reordered. Fields tagged `compact:"-"` are left out. Types implementing
+// encoding.BinaryMarshaler and encoding.BinaryUnmarshaler, such as time.Time, are encoded as binary data.
+// Interface values decode as int64, uint64, float64, bool, string, []byte, []any or map[any]any.
+type CompactCodec[T any] struct{}
+
+func (c *CompactCodec[T]) Marshal(v T) ([]byte, error) {
+	e := compactEncoder{buf: make([]byte, 0, 64)}
+	if err := e.encode(reflect.ValueOf(&v).Elem()); err != nil {
+		return nil, err
+	}
+	return e.buf, nil
+}
+
+func (c *CompactCodec[T]) Unmarshal(data []byte, v *T) error {
+	d := compactDecoder{data: data}
+	if err := d.decode(reflect.ValueOf(v).Elem()); err != nil {
+		return err
+	}
+	if d.pos != len(d.data) {
+		return fmt.Errorf("%w: %d trailing bytes", ErrCompactData, len(d.data)-d.pos)
+	}
+	return nil
+}
+
+// MessagePack type bytes
+const (
+	mpNil      = 0xc0
+	mpFalse    = 0xc2
+	mpTrue     = 0xc3
+	mpBin8     = 0xc4
+	mpBin16    = 0xc5
+	mpBin32    = 0xc6
+	mpFloat32  = 0xca
+	mpFloat64  = 0xcb
+	mpUint8    = 0xcc
+	mpUint16   = 0xcd
+	mpUint32   = 0xce
+	mpUint64   = 0xcf
+	mpInt8     = 0xd0
+	mpInt16    = 0xd1
+	mpInt32    = 0xd2
+	mpInt64    = 0xd3
+	mpStr8     = 0xd9
+	mpStr16    = 0xda
+	mpStr32    = 0xdb
+	mpArray16  = 0xdc
+	mpArray32  = 0xdd
+	mpMap16    = 0xde
+	mpMap32    = 0xdf
+	mpFixMap   = 0x80
+	mpFixArray = 0x90
+	mpFixStr   = 0xa0
+)
+
+var (
+	binaryMarshalerType   = reflect.TypeFor[encoding.BinaryMarshaler]()
+	binaryUnmarshalerType = reflect.TypeFor[encoding.BinaryUnmarshaler]()
+)
+
+// compactType caches what the codec needs to know about a type
+type compactType struct {
+	// binary types implement encoding.BinaryMarshaler and, through a pointer, encoding.BinaryUnmarshaler
+	binary bool
+	// fields are the indexes of the encoded struct fields
+	fields []int
+}
+
+var compactTypes sync.Map
+
+func compactTypeOf(t reflect.Type) *compactType {
+	if ct, ok := compactTypes.Load(t); ok {
+		return ct.(*compactType)
+	}
+	ct := &compactType{
+		binary: t.Kind() != reflect.Pointer && t.Kind() != reflect.Interface &&
+			t.Implements(binaryMarshalerType) && reflect.PointerTo(t).Implements(binaryUnmarshalerType),
+	}
+	if t.Kind() == reflect.Struct {
+		for i := range t.NumField() {
+			f := t.Field(i)
+			if f.IsExported() && f.Tag.Get("compact") != "-" {
+				ct.fields = append(ct.fields, i)
+			}
+		}
+	}
+	actual, _ := compactTypes.LoadOrStore(t, ct)
+	return actual.(*compactType)
+}
+
+type compactEncoder struct {
+	buf []byte
+}
+
+func (e *compactEncoder) encode(v reflect.Value) error {
+	if !v.IsValid() {
+		e.buf = append(e.buf, mpNil)
+		return nil
+	}
+	ct := compactTypeOf(v.Type())
+	if ct.binary {
+		data, err := v.Interface().(encoding.BinaryMarshaler).MarshalBinary()
+		if err != nil {
+			return err
+		}
+		e.writeBin(data)
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			e.buf = append(e.buf, mpTrue)
+		} else {
+			e.buf = append(e.buf, mpFalse)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		e.writeInt(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		e.writeUint(v.Uint())
+	case reflect.Float32:
+		e.buf = append(e.buf, mpFloat32)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, math.Float32bits(float32(v.Float())))
+	case reflect.Float64:
+		e.buf = append(e.buf, mpFloat64)
+		e.buf = binary.BigEndian.AppendUint64(e.buf, math.Float64bits(v.Float()))
+	case reflect.String:
+		e.writeStr(v.String())
+	case reflect.Slice:
+		if v.IsNil() {
+			e.buf = append(e.buf, mpNil)
+			return nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			e.writeBin(v.Bytes())
+			return nil
+		}
+		return e.encodeArray(v)
+	case reflect.Array:
+		return e.encodeArray(v)
+	case reflect.Map:
+		if v.IsNil() {
+			e.buf = append(e.buf, mpNil)
+			return nil
+		}
+		e.writeLen(v.Len(), mpFixMap, 15, mpMap16, mpMap32)
+		iter := v.MapRange()
+		for iter.Next() {
+			if err := e.encode(iter.Key()); err != nil {
+				return err
+			}
+			if err := e.encode(iter.Value()); err != nil {
+				return err
+			}
+		}
+	case reflect.Struct:
+		e.writeLen(len(ct.fields), mpFixArray, 15, mpArray16, mpArray32)
+		for _, i := range ct.fields {
+			if err := e.encode(v.Field(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			e.buf = append(e.buf, mpNil)
+			return nil
+		}
+		return e.encode(v.Elem())
+	default:
+		return fmt.Errorf("codec: compact codec can't encode %s", v.Type())
+	}
+	return nil
+}
+
+func (e *compactEncoder) encodeArray(v reflect.Value) error {
+	e.writeLen(v.Len(), mpFixArray, 15, mpArray16, mpArray32)
+	for i := range v.Len() {
+		if err := e.encode(v.Index(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *compactEncoder) writeInt(n int64) {
+	switch {
+	case n >= 0:
+		e.writeUint(uint64(n))
+	case n >= -32:
+		e.buf = append(e.buf, byte(n))
+	case n >= math.MinInt8:
+		e.buf = append(e.buf, mpInt8, byte(n))
+	case n >= math.MinInt16:
+		e.buf = binary.BigEndian.AppendUint16(append(e.buf, mpInt16), uint16(n))
+	case n >= math.MinInt32:
+		e.buf = binary.BigEndian.AppendUint32(append(e.buf, mpInt32), uint32(n))
+	default:
+		e.buf = binary.BigEndian.AppendUint64(append(e.buf, mpInt64), uint64(n))
+	}
+}
+
+func (e *compactEncoder) writeUint(n uint64) {
+	switch {
+	case n <= 0x7f:
+		e.buf = append(e.buf, byte(n))
+	case n <= math.MaxUint8:
+		e.buf = append(e.buf, mpUint8, byte(n))
+	case n <= math.MaxUint16:
+		e.buf = binary.BigEndian.AppendUint16(append(e.buf, mpUint16), uint16(n))
+	case n <= math.MaxUint32:
+		e.buf = binary.BigEndian.AppendUint32(append(e.buf, mpUint32), uint32(n))
+	default:
+		e.buf = binary.BigEndian.AppendUint64(append(e.buf, mpUint64), n)
+	}
+}
+
+func (e *compactEncoder) writeStr(s string) {
+	switch {
+	case len(s) <= 31:
+		e.buf = append(e.buf, mpFixStr|byte(len(s)))
+	case len(s) <= math.MaxUint8:
+		e.buf = append(e.buf, mpStr8, byte(len(s)))
+	default:
+		e.writeLen(len(s), 0, -1, mpStr16, mpStr32)
+	}
+	e.buf = append(e.buf, s...)
+}
+
+func (e *compactEncoder) writeBin(b []byte) {
+	if len(b) <= math.MaxUint8 {
+		e.buf = append(e.buf, mpBin8, byte(len(b)))
+	} else {
+		e.writeLen(len(b), 0, -1, mpBin16, mpBin32)
+	}
+	e.buf = append(e.buf, b...)
+}
+
+// writeLen writes a length with the fix format if it fits in fixMax, otherwise with the 16 or 32-bit format
+func (e *compactEncoder) writeLen(n int, fix byte, fixMax int, f16, f32 byte) {
+	switch {
+	case n <= fixMax:
+		e.buf = append(e.buf, fix|byte(n))
+	case n <= math.MaxUint16:
+		e.buf = binary.BigEndian.AppendUint16(append(e.buf, f16), uint16(n))
+	default:
+		e.buf = binary.BigEndian.AppendUint32(append(e.buf, f32), uint32(n))
+	}
+}
+
+type compactDecoder struct {
+	data []byte
+	pos  int
+}
+
+func (d *compactDecoder) decode(v reflect.Value) error {
+	if d.pos >= len(d.data) {
+		return fmt.Errorf("%w: unexpected end", ErrCompactData)
+	}
+	if d.data[d.pos] == mpNil {
+		d.pos++
+		v.SetZero()
+		return nil
+	}
+	ct := compactTypeOf(v.Type())
+	if ct.binary && v.CanAddr() {
+		b, err := d.readBytes()
+		if err != nil {
+			return err
+		}
+		return v.Addr().Interface().(encoding.BinaryUnmarshaler).UnmarshalBinary(b)
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		switch d.next() {
+		case mpTrue:
+			v.SetBool(true)
+		case mpFalse:
+			v.SetBool(false)
+		default:
+			return d.typeError(v)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := d.readInt()
+		if err != nil {
+			return err
+		}
+		if v.OverflowInt(n) {
+			return fmt.Errorf("%w: %d overflows %s", ErrCompactData, n, v.Type())
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, err := d.readUint()
+		if err != nil {
+			return err
+		}
+		if v.OverflowUint(n) {
+			return fmt.Errorf("%w: %d overflows %s", ErrCompactData, n, v.Type())
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := d.readFloat()
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.String:
+		b, err := d.readBytes()
+		if err != nil {
+			return err
+		}
+		v.SetString(string(b))
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			b, err := d.readBytes()
+			if err != nil {
+				return err
+			}
+			v.SetBytes(append([]byte{}, b...))
+			return nil
+		}
+		n, err := d.readArrayLen()
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.MakeSlice(v.Type(), n, n))
+		for i := range n {
+			if err := d.decode(v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Array:
+		n, err := d.readArrayLen()
+		if err != nil {
+			return err
+		}
+		v.SetZero()
+		for i := range n {
+			if i >= v.Len() {
+				if err := d.skip(); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := d.decode(v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		n, err := d.readMapLen()
+		if err != nil {
+			return err
+		}
+		t := v.Type()
+		v.Set(reflect.MakeMapWithSize(t, n))
+		for range n {
+			key := reflect.New(t.Key()).Elem()
+			if err := d.decode(key); err != nil {
+				return err
+			}
+			val := reflect.New(t.Elem()).Elem()
+			if err := d.decode(val); err != nil {
+				return err
+			}
+			v.SetMapIndex(key, val)
+		}
+	case reflect.Struct:
+		n, err := d.readArrayLen()
+		if err != nil {
+			return err
+		}
+		v.SetZero()
+		for i := range n {
+			if i >= len(ct.fields) {
+				if err := d.skip(); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := d.decode(v.Field(ct.fields[i])); err != nil {
+				return err
+			}
+		}
+	case reflect.Pointer:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return d.decode(v.Elem())
+	case reflect.Interface:
+		if v.NumMethod() != 0 {
+			return fmt.Errorf("codec: compact codec can't decode into %s", v.Type())
+		}
+		val, err := d.readAny()
+		if err != nil {
+			return err
+		}
+		if val != nil {
+			v.Set(reflect.ValueOf(val))
+		}
+	default:
+		return fmt.Errorf("codec: compact codec can't decode into %s", v.Type())
+	}
+	return nil
+}
+
+func (d *compactDecoder) typeError(v reflect.Value) error {
+	return fmt.Errorf("%w: type byte 0x%x at %d for %s", ErrCompactData, d.data[d.pos-1], d.pos-1, v.Type())
+}
+
+// next returns the next byte, or mpNil past the end, which no reader accepts where a value is expected
+func (d *compactDecoder) next() byte {
+	if d.pos >= len(d.data) {
+		return mpNil
+	}
+	b := d.data[d.pos]
+	d.pos++
+	return b
+}
+
+func (d *compactDecoder) take(n int) ([]byte, error) {
+	if n < 0 || n > len(d.data)-d.pos {
+		return nil, fmt.Errorf("%w: unexpected end", ErrCompactData)
+	}
+	b := d.data[d.pos : d.pos+n]
+	d.pos += n
+	return b, nil
+}
+
+func (d *compactDecoder) readN(size int) (uint64, error) {
+	b, err := d.take(size)
+	if err != nil {
+		return 0, err
+	}
+	switch size {
+	case 1:
+		return uint64(b[0]), nil
+	case 2:
+		return uint64(binary.BigEndian.Uint16(b)), nil
+	case 4:
+		return uint64(binary.BigEndian.Uint32(b)), nil
+	default:
+		return binary.BigEndian.Uint64(b), nil
+	}
+}
+
+// readInt reads any integer format; uint64 values above math.MaxInt64 are rejected
+func (d *compactDecoder) readInt() (int64, error) {
+	b := d.next()
+	switch {
+	case b <= 0x7f:
+		return int64(b), nil
+	case b >= 0xe0:
+		return int64(int8(b)), nil
+	}
+	switch b {
+	case mpUint8, mpUint16, mpUint32, mpUint64:
+		n, err := d.readN(1 << (b - mpUint8))
+		if err != nil {
+			return 0, err
+		}
+		if n > math.MaxInt64 {
+			return 0, fmt.Errorf("%w: %d overflows int64", ErrCompactData, n)
+		}
+		return int64(n), nil
+	case mpInt8:
+		n, err := d.readN(1)
+		return int64(int8(n)), err
+	case mpInt16:
+		n, err := d.readN(2)
+		return int64(int16(n)), err
+	case mpInt32:
+		n, err := d.readN(4)
+		return int64(int32(n)), err
+	case mpInt64:
+		n, err := d.readN(8)
+		return int64(n), err
+	}
+	return 0, fmt.Errorf("%w: type byte 0x%x at %d is not an integer", ErrCompactData, b, d.pos-1)
+}
+
+// readUint reads any integer format into a uint64; negative values are rejected
+func (d *compactDecoder) readUint() (uint64, error) {
+	if b := d.data[d.pos]; b >= mpUint8 && b <= mpUint64 {
+		d.pos++
+		return d.readN(1 << (b - mpUint8))
+	}
+	n, err := d.readInt()
+	if err != nil {
+		return 0, err
+	}
+	if n < 0 {
+		return 0, fmt.Errorf("%w: %d overflows uint64", ErrCompactData, n)
+	}
+	return uint64(n), nil
+}
+
+func (d *compactDecoder) readFloat() (float64, error) {
+	switch d.data[d.pos] {
+	case mpFloat32:
+		d.pos++
+		n, err := d.readN(4)
+		return float64(math.Float32frombits(uint32(n))), err
+	case mpFloat64:
+		d.pos++
+		n, err := d.readN(8)
+		return math.Float64frombits(n), err
+	}
+	n, err := d.readInt()
+	return float64(n), err
+}
+
+// readBytes reads a string or binary value without copying it
+func (d *compactDecoder) readBytes() ([]byte, error) {
+	b := d.next()
+	var (
+		n   uint64
+		err error
+	)
+	switch {
+	case b&0xe0 == mpFixStr:
+		n = uint64(b & 0x1f)
+	case b == mpStr8 || b == mpBin8:
+		n, err = d.readN(1)
+	case b == mpStr16 || b == mpBin16:
+		n, err = d.readN(2)
+	case b == mpStr32 || b == mpBin32:
+		n, err = d.readN(4)
+	default:
+		return nil, fmt.Errorf("%w: type byte 0x%x at %d is not a string", ErrCompactData, b, d.pos-1)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return d.take(int(n))
+}
+
+func (d *compactDecoder) readArrayLen() (int, error) {
+	return d.readLen(mpFixArray, 0x0f, mpArray16, mpArray32, 1)
+}
+
+func (d *compactDecoder) readMapLen() (int, error) {
+	return d.readLen(mpFixMap, 0x0f, mpMap16, mpMap32, 2)
+}
+
+// readLen reads a container length. Every element takes at least one byte, so lengths beyond
+// the remaining data are rejected before anything is allocated.
+func (d *compactDecoder) readLen(fix, fixMask, f16, f32 byte, perElem int) (int, error) {
+	b := d.next()
+	var (
+		n   uint64
+		err error
+	)
+	switch {
+	case b&^fixMask == fix:
+		n = uint64(b & fixMask)
+	case b == f16:
+		n, err = d.readN(2)
+	case b == f32:
+		n, err = d.readN(4)
+	default:
+		return 0, fmt.Errorf("%w: type byte 0x%x at %d is not a container", ErrCompactData, b, d.pos-1)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if n*uint64(perElem) > uint64(len(d.data)-d.pos) {
+		return 0, fmt.Errorf("%w: length %d exceeds data", ErrCompactData, n)
+	}
+	return int(n), nil
+}
+
+// readAny decodes the next value into the generic Go types: int64, float64, bool, string,
+// []byte, []any and map[any]any
+func (d *compactDecoder) readAny() (any, error) {
+	if d.pos >= len(d.data) {
+		return nil, fmt.Errorf("%w: unexpected end", ErrCompactData)
+	}
+	b := d.data[d.pos]
+	switch {
+	case b == mpNil:
+		d.pos++
+		return nil, nil
+	case b == mpTrue || b == mpFalse:
+		d.pos++
+		return b == mpTrue, nil
+	case b == mpFloat32 || b == mpFloat64:
+		return d.readFloat()
+	case b <= 0x7f || b >= 0xe0 || (b >= mpUint8 && b <= mpInt64):
+		if b == mpUint64 {
+			d.pos++
+			return d.readN(8)
+		}
+		return d.readInt()
+	case b >= mpBin8 && b <= mpBin32:
+		bin, err := d.readBytes()
+		return append([]byte{}, bin...), err
+	case b&0xe0 == mpFixStr || (b >= mpStr8 && b <= mpStr32):
+		s, err := d.readBytes()
+		return string(s), err
+	case b&0xf0 == mpFixArray || b == mpArray16 || b == mpArray32:
+		n, err := d.readArrayLen()
+		if err != nil {
+			return nil, err
+		}
+		ret := make([]any, n)
+		for i := range ret {
+			if ret[i], err = d.readAny(); err != nil {
+				return nil, err
+			}
+		}
+		return ret, nil
+	case b&0xf0 == mpFixMap || b == mpMap16 || b == mpMap32:
+		n, err := d.readMapLen()
+		if err != nil {
+			return nil, err
+		}
+		ret := make(map[any]any, n)
+		for range n {
+			k, err := d.readAny()
+			if err != nil {
+				return nil, err
+			}
+			if k != nil && !reflect.TypeOf(k).Comparable() {
+				return nil, fmt.Errorf("%w: map key of type %T", ErrCompactData, k)
+			}
+			if ret[k], err = d.readAny(); err != nil {
+				return nil, err
+			}
+		}
+		return ret, nil
+	}
+	return nil, fmt.Errorf("%w: unsupported type byte 0x%x at %d", ErrCompactData, b, d.pos)
+}
+
+func (d *compactDecoder) skip() error {
+	_, err := d.readAny()
+	return err
+}
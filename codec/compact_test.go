@@ -0,0 +1,176 @@
+package codec
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type profile struct {
+	ID       int64
+	Name     string
+	Active   bool
+	Ratio    float32
+	Score    float64
+	Tags     []string
+	Counts   map[string]uint16
+	Avatar   []byte
+	Created  time.Time
+	Parent   *profile
+	Extra    any
+	Grid     [2]int8
+	internal int
+	Skipped  string `compact:"-"`
+}
+
+func TestCompactCodec(t *testing.T) {
+	c := &CompactCodec[profile]{}
+	v := profile{
+		ID:      -1 << 40,
+		Name:    "gopher",
+		Active:  true,
+		Ratio:   0.5,
+		Score:   math.Pi,
+		Tags:    []string{"a", "", "a long tag that needs more than thirty-one bytes"},
+		Counts:  map[string]uint16{"x": 1, "y": 65535},
+		Avatar:  []byte{0, 1, 2},
+		Created: time.Date(2024, 5, 6, 7, 8, 9, 10, time.UTC),
+		Parent:  &profile{ID: 7, Extra: []any{int64(1), "two", map[any]any{"k": true}}},
+		Grid:    [2]int8{-128, 127},
+	}
+	data, err := c.Marshal(v)
+	assert.Nil(t, err)
+	var got profile
+	assert.Nil(t, c.Unmarshal(data, &got))
+	assert.Equal(t, v, got)
+
+	// unexported and skipped fields are not encoded; a single map entry keeps the output comparable
+	v.Counts = map[string]uint16{"x": 1}
+	data, err = c.Marshal(v)
+	assert.Nil(t, err)
+	v.internal, v.Skipped = 1, "x"
+	data2, err := c.Marshal(v)
+	assert.Nil(t, err)
+	assert.Equal(t, data, data2)
+
+	u := &CompactCodec[uint64]{}
+	for _, n := range []uint64{0, math.MaxUint32 + 1, math.MaxInt64 + 1, ^uint64(0)} {
+		data, err := u.Marshal(n)
+		assert.Nil(t, err)
+		var got uint64
+		assert.Nil(t, u.Unmarshal(data, &got))
+		assert.Equal(t, n, got)
+	}
+	var negative uint64
+	assert.ErrorIs(t, u.Unmarshal([]byte{0xff}, &negative), ErrCompactData)
+}
+
+func TestCompactCodecFormat(t *testing.T) {
+	cases := []struct {
+		v    any
+		want []byte
+	}{
+		{1, []byte{0x01}},
+		{-1, []byte{0xff}},
+		{300, []byte{0xcd, 0x01, 0x2c}},
+		{-200, []byte{0xd1, 0xff, 0x38}},
+		{"a", []byte{0xa1, 'a'}},
+		{[]int{1, 2}, []byte{0x92, 0x01, 0x02}},
+		{map[string]bool{"k": true}, []byte{0x81, 0xa1, 'k', 0xc3}},
+		{nil, []byte{0xc0}},
+	}
+	c := &CompactCodec[any]{}
+	for _, tc := range cases {
+		data, err := c.Marshal(tc.v)
+		assert.Nil(t, err)
+		assert.Equal(t, tc.want, data, "%v", tc.v)
+	}
+}
+
+type userV1 struct {
+	ID   int
+	Name string
+}
+
+type userV2 struct {
+	ID    int
+	Name  string
+	Email string
+}
+
+func TestCompactCodecEvolution(t *testing.T) {
+	old, _ := (&CompactCodec[userV1]{}).Marshal(userV1{ID: 1, Name: "a"})
+	var v2 userV2
+	assert.Nil(t, (&CompactCodec[userV2]{}).Unmarshal(old, &v2))
+	assert.Equal(t, userV2{ID: 1, Name: "a"}, v2)
+
+	updated, _ := (&CompactCodec[userV2]{}).Marshal(userV2{ID: 2, Name: "b", Email: "c"})
+	var v1 userV1
+	assert.Nil(t, (&CompactCodec[userV1]{}).Unmarshal(updated, &v1))
+	assert.Equal(t, userV1{ID: 2, Name: "b"}, v1)
+}
+
+func TestCompactCodecInvalid(t *testing.T) {
+	c := &CompactCodec[[]string]{}
+	for _, data := range [][]byte{
+		{},
+		{0x92, 0xa1},
+		{0xdd, 0xff, 0xff, 0xff, 0xff},
+		{0x91, 0x01},
+		{0x90, 0x00},
+	} {
+		var v []string
+		assert.ErrorIs(t, c.Unmarshal(data, &v), ErrCompactData, "%x", data)
+	}
+
+	var small int8
+	assert.ErrorIs(t, (&CompactCodec[int8]{}).Unmarshal([]byte{0xcd, 0x01, 0x2c}, &small), ErrCompactData)
+	_, err := (&CompactCodec[chan int]{}).Marshal(make(chan int))
+	assert.NotNil(t, err)
+}
+
+func TestGobCodec(t *testing.T) {
+	c := &GobCodec[userV2]{}
+	data, err := c.Marshal(userV2{ID: 1, Name: "a", Email: "b"})
+	assert.Nil(t, err)
+	var got userV2
+	assert.Nil(t, c.Unmarshal(data, &got))
+	assert.Equal(t, userV2{ID: 1, Name: "a", Email: "b"}, got)
+}
+
+func BenchmarkCodecs(b *testing.B) {
+	small := payload{ID: 42, Name: "user-42", Tags: []string{"a", "b"}, Score: 0.5}
+	large := newPayload(200)
+	benchCodec(b, "small", small, map[string]Codec[payload]{
+		"json": &JsonCodec[payload]{}, "gob": &GobCodec[payload]{}, "compact": &CompactCodec[payload]{},
+	})
+	benchCodec(b, "large", large, map[string]Codec[[]payload]{
+		"json": &JsonCodec[[]payload]{}, "gob": &GobCodec[[]payload]{}, "compact": &CompactCodec[[]payload]{},
+	})
+}
+
+func benchCodec[T any](b *testing.B, name string, v T, codecs map[string]Codec[T]) {
+	for _, codecName := range []string{"json", "gob", "compact"} {
+		c := codecs[codecName]
+		data, err := c.Marshal(v)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(name+"/"+codecName+"/marshal", func(b *testing.B) {
+			b.ReportAllocs()
+			b.ReportMetric(float64(len(data)), "bytes")
+			for range b.N {
+				_, _ = c.Marshal(v)
+			}
+		})
+		b.Run(name+"/"+codecName+"/unmarshal", func(b *testing.B) {
+			b.ReportAllocs()
+			for range b.N {
+				var got T
+				_ = c.Unmarshal(data, &got)
+			}
+		})
+	}
+}
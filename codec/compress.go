@@ -0,0 +1,183 @@
+package codec
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Compression is the algorithm used by CompressCodec
+type Compression byte
+
+// The values double as the header byte of compressed data
+const (
+	Gzip  Compression = 0x01
+	Flate Compression = 0x02
+)
+
+// headerRaw marks uncompressed data that would otherwise be mistaken for a header
+const headerRaw byte = 0x00
+
+// CompressCodec wraps a codec and compresses its output when it is at least threshold bytes.
+// Compressed data starts with a one-byte header naming the algorithm. Smaller output is stored as is,
+// so values written before the codec was introduced are still read; only output that happens to start
+// with a header byte gets an extra 0x00 byte. Both algorithms are always decoded, so the algorithm can be
+// changed on a cache in use.
+type CompressCodec[T any] struct {
+	codec     Codec[T]
+	algo      Compression
+	level     int
+	threshold int
+
+	gzipWriters  sync.Pool
+	flateWriters sync.Pool
+	gzipReaders  sync.Pool
+	flateReaders sync.Pool
+	buffers      sync.Pool
+}
+
+// NewCompressCodec compresses the output of codec with algo if it is at least threshold bytes.
+func NewCompressCodec[T any](codec Codec[T], algo Compression, threshold int) *CompressCodec[T] {
+	return NewCompressCodecLevel(codec, algo, threshold, flate.DefaultCompression)
+}
+
+// NewCompressCodecLevel is NewCompressCodec with a compression level, see compress/flate.
+// The level is fixed for the lifetime of the codec, as its pooled writers are created with it.
+func NewCompressCodecLevel[T any](codec Codec[T], algo Compression, threshold, level int) *CompressCodec[T] {
+	c := &CompressCodec[T]{
+		codec:     codec,
+		algo:      algo,
+		level:     level,
+		threshold: threshold,
+	}
+	c.buffers.New = func() any { return new(bytes.Buffer) }
+	return c
+}
+
+func (c *CompressCodec[T]) Marshal(v T) ([]byte, error) {
+	return c.marshal("", false, v)
+}
+
+func (c *CompressCodec[T]) MarshalKey(key string, v T) ([]byte, error) {
+	return c.marshal(key, true, v)
+}
+
+func (c *CompressCodec[T]) Unmarshal(data []byte, v *T) error {
+	return c.unmarshal("", false, data, v)
+}
+
+func (c *CompressCodec[T]) UnmarshalKey(key string, data []byte, v *T) error {
+	return c.unmarshal(key, true, data, v)
+}
+
+func (c *CompressCodec[T]) marshal(key string, keyed bool, v T) ([]byte, error) {
+	data, err := marshalInner(c.codec, key, keyed, v)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < c.threshold {
+		if len(data) > 0 && isHeader(data[0]) {
+			return append([]byte{headerRaw}, data...), nil
+		}
+		return data, nil
+	}
+
+	out := bytes.NewBuffer(make([]byte, 0, len(data)/4+16))
+	out.WriteByte(byte(c.algo))
+	if err := c.compress(out, data); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+func (c *CompressCodec[T]) compress(out *bytes.Buffer, data []byte) error {
+	switch c.algo {
+	case Gzip:
+		w, _ := c.gzipWriters.Get().(*gzip.Writer)
+		if w == nil {
+			var err error
+			if w, err = gzip.NewWriterLevel(out, c.level); err != nil {
+				return err
+			}
+		} else {
+			w.Reset(out)
+		}
+		defer c.gzipWriters.Put(w)
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+		return w.Close()
+	case Flate:
+		w, _ := c.flateWriters.Get().(*flate.Writer)
+		if w == nil {
+			var err error
+			if w, err = flate.NewWriter(out, c.level); err != nil {
+				return err
+			}
+		} else {
+			w.Reset(out)
+		}
+		defer c.flateWriters.Put(w)
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+		return w.Close()
+	default:
+		return fmt.Errorf("codec: unknown compression %d", c.algo)
+	}
+}
+
+func (c *CompressCodec[T]) unmarshal(key string, keyed bool, data []byte, v *T) error {
+	if len(data) == 0 || !isHeader(data[0]) {
+		return unmarshalInner(c.codec, key, keyed, data, v)
+	}
+	if data[0] == headerRaw {
+		return unmarshalInner(c.codec, key, keyed, data[1:], v)
+	}
+
+	buf := c.buffers.Get().(*bytes.Buffer)
+	buf.Reset()
+	defer c.buffers.Put(buf)
+	if err := c.decompress(buf, Compression(data[0]), data[1:]); err != nil {
+		return err
+	}
+	return unmarshalInner(c.codec, key, keyed, buf.Bytes(), v)
+}
+
+func (c *CompressCodec[T]) decompress(out *bytes.Buffer, algo Compression, data []byte) error {
+	var r io.ReadCloser
+	switch algo {
+	case Gzip:
+		zr, _ := c.gzipReaders.Get().(*gzip.Reader)
+		if zr == nil {
+			var err error
+			if zr, err = gzip.NewReader(bytes.NewReader(data)); err != nil {
+				return err
+			}
+		} else if err := zr.Reset(bytes.NewReader(data)); err != nil {
+			return err
+		}
+		defer c.gzipReaders.Put(zr)
+		r = zr
+	default:
+		fr, _ := c.flateReaders.Get().(io.ReadCloser)
+		if fr == nil {
+			fr = flate.NewReader(bytes.NewReader(data))
+		} else if err := fr.(flate.Resetter).Reset(bytes.NewReader(data), nil); err != nil {
+			return err
+		}
+		defer c.flateReaders.Put(fr)
+		r = fr
+	}
+	if _, err := out.ReadFrom(r); err != nil {
+		return err
+	}
+	return r.Close()
+}
+
+func isHeader(b byte) bool {
+	return b == headerRaw || b == byte(Gzip) || b == byte(Flate)
+}
@@ -0,0 +1,121 @@
+package codec
+
+import (
+	"compress/flate"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type payload struct {
+	ID    int
+	Name  string
+	Tags  []string
+	Score float64
+}
+
+func newPayload(n int) []payload {
+	ret := make([]payload, n)
+	for i := range ret {
+		ret[i] = payload{ID: i, Name: fmt.Sprintf("user-%d", i), Tags: []string{"a", "b", "c"}, Score: float64(i) / 3}
+	}
+	return ret
+}
+
+func TestCompressCodec(t *testing.T) {
+	for _, algo := range []Compression{Gzip, Flate} {
+		c := NewCompressCodec[[]payload](&JsonCodec[[]payload]{}, algo, 256)
+
+		v := newPayload(50)
+		data, err := c.Marshal(v)
+		assert.Nil(t, err)
+		assert.Equal(t, byte(algo), data[0])
+		raw, _ := (&JsonCodec[[]payload]{}).Marshal(v)
+		assert.Less(t, len(data)*3, len(raw))
+
+		// reuse pooled writers and readers
+		for range 3 {
+			var got []payload
+			assert.Nil(t, c.Unmarshal(data, &got))
+			assert.Equal(t, v, got)
+		}
+
+		// small output is left as is
+		small := newPayload(1)
+		data, err = c.Marshal(small)
+		assert.Nil(t, err)
+		assert.Equal(t, byte('['), data[0])
+		var got []payload
+		assert.Nil(t, c.Unmarshal(data, &got))
+		assert.Equal(t, small, got)
+
+		// the level is set on construction
+		stored, err := NewCompressCodecLevel[[]payload](&JsonCodec[[]payload]{}, algo, 256, flate.NoCompression).Marshal(v)
+		assert.Nil(t, err)
+		assert.Greater(t, len(stored), len(raw))
+		assert.Nil(t, c.Unmarshal(stored, &got))
+		assert.Equal(t, v, got)
+		_, err = NewCompressCodecLevel[[]payload](&JsonCodec[[]payload]{}, algo, 256, 42).Marshal(v)
+		assert.NotNil(t, err)
+	}
+
+	// data written with the other algorithm and before compression is still read
+	gz := NewCompressCodec[string](&JsonCodec[string]{}, Gzip, 1)
+	fl := NewCompressCodec[string](&JsonCodec[string]{}, Flate, 1)
+	data, _ := gz.Marshal("hello")
+	var s string
+	assert.Nil(t, fl.Unmarshal(data, &s))
+	assert.Equal(t, "hello", s)
+	assert.Nil(t, fl.Unmarshal([]byte(`"old"`), &s))
+	assert.Equal(t, "old", s)
+}
+
+type bytesCodec struct{}
+
+func (bytesCodec) Marshal(v []byte) ([]byte, error) { return v, nil }
+func (bytesCodec) Unmarshal(data []byte, v *[]byte) error {
+	*v = append([]byte{}, data...)
+	return nil
+}
+
+func TestCompressCodecRawHeader(t *testing.T) {
+	c := NewCompressCodec[[]byte](bytesCodec{}, Gzip, 100)
+	for _, v := range [][]byte{{0x00, 'a'}, {0x01}, {0x02, 0x03}, {}} {
+		data, err := c.Marshal(v)
+		assert.Nil(t, err)
+		var got []byte
+		assert.Nil(t, c.Unmarshal(data, &got))
+		assert.Equal(t, v, got)
+	}
+}
+
+func BenchmarkCompressCodec(b *testing.B) {
+	v := newPayload(200)
+	codecs := []struct {
+		name  string
+		codec Codec[[]payload]
+	}{
+		{"json", &JsonCodec[[]payload]{}},
+		{"gzip", NewCompressCodec[[]payload](&JsonCodec[[]payload]{}, Gzip, 0)},
+		{"flate", NewCompressCodec[[]payload](&JsonCodec[[]payload]{}, Flate, 0)},
+		{"flate-speed", NewCompressCodecLevel[[]payload](&JsonCodec[[]payload]{}, Flate, 0, flate.BestSpeed)},
+	}
+	for _, c := range codecs {
+		data, _ := c.codec.Marshal(v)
+		b.Run(c.name+"/marshal", func(b *testing.B) {
+			b.ReportAllocs()
+			b.ReportMetric(float64(len(data)), "bytes")
+			for range b.N {
+				_, _ = c.codec.Marshal(v)
+			}
+		})
+		b.Run(c.name+"/unmarshal", func(b *testing.B) {
+			b.ReportAllocs()
+			for range b.N {
+				var got []payload
+				_ = c.codec.Unmarshal(data, &got)
+			}
+		})
+	}
+}
@@ -0,0 +1,22 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/gob"
+)
+
+// GobCodec encodes values with encoding/gob. Every value carries its type description,
+// so it suits larger values better than small ones; see CompactCodec for those.
+type GobCodec[T any] struct{}
+
+func (c *GobCodec[T]) Marshal(v T) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (c *GobCodec[T]) Unmarshal(data []byte, v *T) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
@@ -0,0 +1,187 @@
+package codec
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/mbeoliero/tiercache/internal/convert"
+)
+
+// ErrKeyNotDecodable is returned when a key can't be decoded, e.g. because it was hashed
+var ErrKeyNotDecodable = errors.New("codec: key is not decodable")
+
+// KeyEncoder turns cache keys into the strings used as store keys.
+// Different keys must encode to different strings.
+type KeyEncoder[K any] interface {
+	EncodeKey(K) string
+}
+
+// KeyDecoder recovers a typed key from its encoded form, e.g. for scanning or exporting a store.
+type KeyDecoder[K any] interface {
+	DecodeKey(string) (K, error)
+}
+
+// KeyEncoderFunc adapts a function to a KeyEncoder
+type KeyEncoderFunc[K any] func(K) string
+
+func (f KeyEncoderFunc[K]) EncodeKey(k K) string {
+	return f(k)
+}
+
+// DefaultKey encodes keys like previous versions did: strings and numbers as is, fmt.Stringer with String
+// and anything else as JSON. It is the default for compatibility, but JSON depends on the field order of
+// structs and a Stringer can collide with a plain string, so prefer one of the other encoders.
+type DefaultKey[K any] struct{}
+
+func (DefaultKey[K]) EncodeKey(k K) string {
+	return convert.ToString(k)
+}
+
+// Integer is the set of integer key types
+type Integer interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 | ~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr
+}
+
+// IntKey encodes integer keys in base 10
+type IntKey[K Integer] struct{}
+
+func (IntKey[K]) EncodeKey(k K) string {
+	if ^K(0) < 0 {
+		return strconv.FormatInt(int64(k), 10)
+	}
+	return strconv.FormatUint(uint64(k), 10)
+}
+
+func (IntKey[K]) DecodeKey(s string) (K, error) {
+	if ^K(0) < 0 {
+		v, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || int64(K(v)) != v {
+			return 0, errors.Join(ErrKeyNotDecodable, err)
+		}
+		return K(v), nil
+	}
+	v, err := strconv.ParseUint(s, 10, 64)
+	if err != nil || uint64(K(v)) != v {
+		return 0, errors.Join(ErrKeyNotDecodable, err)
+	}
+	return K(v), nil
+}
+
+// StringKey encodes string keys as is
+type StringKey[K ~string] struct{}
+
+func (StringKey[K]) EncodeKey(k K) string {
+	return string(k)
+}
+
+func (StringKey[K]) DecodeKey(s string) (K, error) {
+	return K(s), nil
+}
+
+// CompositeKey encodes keys made of several parts, e.g. a tenant and a user id, by joining the parts
+// with Sep. Occurrences of Sep and of the escape character '\' in the parts are escaped, so different
+// keys never collide.
+type CompositeKey[K any] struct {
+	// Sep separates the parts, ':' if zero
+	Sep byte
+	// Parts splits a key into its parts
+	Parts func(K) []string
+	// FromParts builds a key from its parts; without it the key can't be decoded
+	FromParts func([]string) (K, error)
+}
+
+func (c CompositeKey[K]) sep() byte {
+	if c.Sep == 0 {
+		return ':'
+	}
+	return c.Sep
+}
+
+func (c CompositeKey[K]) EncodeKey(k K) string {
+	sep := c.sep()
+	var b strings.Builder
+	for i, part := range c.Parts(k) {
+		if i > 0 {
+			b.WriteByte(sep)
+		}
+		for j := 0; j < len(part); j++ {
+			if part[j] == sep || part[j] == '\\' {
+				b.WriteByte('\\')
+			}
+			b.WriteByte(part[j])
+		}
+	}
+	return b.String()
+}
+
+func (c CompositeKey[K]) DecodeKey(s string) (K, error) {
+	if c.FromParts == nil {
+		var zero K
+		return zero, ErrKeyNotDecodable
+	}
+	sep := c.sep()
+	var (
+		parts []string
+		part  []byte
+	)
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && i+1 < len(s):
+			i++
+			part = append(part, s[i])
+		case s[i] == sep:
+			parts = append(parts, string(part))
+			part = part[:0]
+		default:
+			part = append(part, s[i])
+		}
+	}
+	return c.FromParts(append(parts, string(part)))
+}
+
+// HashedKey keeps encoded keys short: keys longer than MaxLen are replaced by their first PrefixLen bytes,
+// which keep them readable in tools like redis-cli, followed by '#' and the hex SHA-256 of the full key.
+// Shorter keys that already end in '#' and 64 hex digits are hashed too, so they can't collide with a hashed key
+// however MaxLen and PrefixLen are set.
+// Hashed keys can't be decoded; other keys are decoded by Encoder if it is a KeyDecoder.
+type HashedKey[K any] struct {
+	Encoder   KeyEncoder[K]
+	MaxLen    int
+	PrefixLen int
+}
+
+func (h HashedKey[K]) EncodeKey(k K) string {
+	s := h.Encoder.EncodeKey(k)
+	if len(s) <= h.MaxLen && !isHashed(s) {
+		return s
+	}
+	sum := sha256.Sum256([]byte(s))
+	return s[:min(h.PrefixLen, len(s))] + "#" + hex.EncodeToString(sum[:])
+}
+
+func (h HashedKey[K]) DecodeKey(s string) (K, error) {
+	dec, ok := h.Encoder.(KeyDecoder[K])
+	// an encoded key longer than MaxLen could only be hashed
+	if !ok || len(s) > h.MaxLen || isHashed(s) {
+		var zero K
+		return zero, ErrKeyNotDecodable
+	}
+	return dec.DecodeKey(s)
+}
+
+// isHashed reports whether s ends like a HashedKey hash: '#' followed by 64 lowercase hex digits
+func isHashed(s string) bool {
+	const n = 1 + 2*sha256.Size
+	if len(s) < n || s[len(s)-n] != '#' {
+		return false
+	}
+	for _, c := range s[len(s)-n+1:] {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
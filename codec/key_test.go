@@ -0,0 +1,80 @@
+package codec
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIntKey(t *testing.T) {
+	assert.Equal(t, "-42", IntKey[int8]{}.EncodeKey(-42))
+	assert.Equal(t, "18446744073709551615", IntKey[uint64]{}.EncodeKey(^uint64(0)))
+
+	v, err := IntKey[int16]{}.DecodeKey("-300")
+	assert.Nil(t, err)
+	assert.Equal(t, int16(-300), v)
+	_, err = IntKey[int8]{}.DecodeKey("300")
+	assert.ErrorIs(t, err, ErrKeyNotDecodable)
+	_, err = IntKey[uint]{}.DecodeKey("-1")
+	assert.ErrorIs(t, err, ErrKeyNotDecodable)
+}
+
+type tenantKey struct {
+	Tenant string
+	User   string
+}
+
+func TestCompositeKey(t *testing.T) {
+	enc := CompositeKey[tenantKey]{
+		Parts: func(k tenantKey) []string { return []string{k.Tenant, k.User} },
+		FromParts: func(parts []string) (tenantKey, error) {
+			if len(parts) != 2 {
+				return tenantKey{}, ErrKeyNotDecodable
+			}
+			return tenantKey{Tenant: parts[0], User: parts[1]}, nil
+		},
+	}
+
+	// separators in the parts don't make different keys collide
+	a := enc.EncodeKey(tenantKey{Tenant: "a:b", User: "c"})
+	b := enc.EncodeKey(tenantKey{Tenant: "a", User: "b:c"})
+	assert.Equal(t, `a\:b:c`, a)
+	assert.NotEqual(t, a, b)
+
+	for _, k := range []tenantKey{{"a:b", "c"}, {"a", "b:c"}, {`x\`, `:y\:`}, {"", ""}} {
+		got, err := enc.DecodeKey(enc.EncodeKey(k))
+		assert.Nil(t, err)
+		assert.Equal(t, k, got)
+	}
+}
+
+func TestHashedKey(t *testing.T) {
+	enc := HashedKey[string]{Encoder: StringKey[string]{}, MaxLen: 32, PrefixLen: 8}
+
+	assert.Equal(t, "short", enc.EncodeKey("short"))
+	long := strings.Repeat("report:", 10)
+	hashed := enc.EncodeKey(long)
+	assert.True(t, strings.HasPrefix(hashed, "report:r#"))
+	assert.Len(t, hashed, 8+1+64)
+	assert.NotEqual(t, hashed, enc.EncodeKey(long+"x"))
+
+	k, err := enc.DecodeKey("short")
+	assert.Nil(t, err)
+	assert.Equal(t, "short", k)
+	_, err = enc.DecodeKey(hashed)
+	assert.ErrorIs(t, err, ErrKeyNotDecodable)
+
+	// with room for a whole hash below MaxLen, a plain key shaped like a hash must not collide with one
+	wide := HashedKey[string]{Encoder: StringKey[string]{}, MaxLen: 128, PrefixLen: 8}
+	long = strings.Repeat("report:", 20)
+	hashed = wide.EncodeKey(long)
+	assert.Len(t, hashed, 8+1+64)
+	assert.NotEqual(t, hashed, wide.EncodeKey(hashed))
+	assert.Len(t, wide.EncodeKey(hashed), 8+1+64)
+	_, err = wide.DecodeKey(hashed)
+	assert.ErrorIs(t, err, ErrKeyNotDecodable)
+	k, err = wide.DecodeKey("report#not-a-hash")
+	assert.Nil(t, err)
+	assert.Equal(t, "report#not-a-hash", k)
+}
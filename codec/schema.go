@@ -0,0 +1,157 @@
+package codec
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+// ErrDiscard marks decode errors of values that can never be decoded and should be deleted from the store.
+// RedisCache deletes such values instead of only skipping them.
+var ErrDiscard = errors.New("codec: discard value")
+
+// ErrUnknownSchema is returned for values with a schema version the SchemaCodec can't migrate
+var ErrUnknownSchema = errors.New("codec: unknown schema version")
+
+// schemaHeader starts values written by SchemaCodec. It is an ASCII control character,
+// so it can't start a JSON document.
+const schemaHeader byte = 0x1e
+
+// Migration converts the encoded payload of one schema version to the next version
+type Migration func(data []byte) ([]byte, error)
+
+// MigrateFunc builds a Migration that decodes the payload with from, converts it with fn and encodes it with to.
+func MigrateFunc[Old, New any](from Codec[Old], to Codec[New], fn func(Old) (New, error)) Migration {
+	return func(data []byte) ([]byte, error) {
+		var old Old
+		if err := from.Unmarshal(data, &old); err != nil {
+			return nil, err
+		}
+		v, err := fn(old)
+		if err != nil {
+			return nil, err
+		}
+		return to.Marshal(v)
+	}
+}
+
+// SchemaCodec wraps a codec and writes a schema version with each value, as a header byte followed by the
+// version as a uvarint. On read, values of older versions are brought up to date by the registered migrations,
+// one version at a time. Values without the header, e.g. written before the SchemaCodec was introduced,
+// are version 0. Values of newer or unmigratable versions fail with ErrUnknownSchema, which RedisCache
+// treats as a miss.
+type SchemaCodec[T any] struct {
+	codec          Codec[T]
+	version        uint32
+	migrations     map[uint32]Migration
+	discardUnknown bool
+	onMigrate      func(from, to uint32)
+	migrated       atomic.Uint64
+}
+
+// NewSchemaCodec writes values with codec and the current schema version.
+func NewSchemaCodec[T any](codec Codec[T], version uint32) *SchemaCodec[T] {
+	return &SchemaCodec[T]{
+		codec:      codec,
+		version:    version,
+		migrations: make(map[uint32]Migration),
+	}
+}
+
+// AddMigration registers the migration from version from to version from+1.
+// Migrations must be registered before the codec is used.
+func (c *SchemaCodec[T]) AddMigration(from uint32, m Migration) *SchemaCodec[T] {
+	c.migrations[from] = m
+	return c
+}
+
+// SetDiscardUnknown makes values with an unknown version fail with ErrDiscard as well,
+// so RedisCache deletes them. Leave it off while different versions of a service share a cache,
+// or the old instances would delete the values of the new ones.
+func (c *SchemaCodec[T]) SetDiscardUnknown(discard bool) *SchemaCodec[T] {
+	c.discardUnknown = discard
+	return c
+}
+
+// SetOnMigrate sets a hook called for every value migrated on read, e.g. to export a metric
+func (c *SchemaCodec[T]) SetOnMigrate(fn func(from, to uint32)) *SchemaCodec[T] {
+	c.onMigrate = fn
+	return c
+}
+
+// Migrations returns the number of values migrated on read
+func (c *SchemaCodec[T]) Migrations() uint64 {
+	return c.migrated.Load()
+}
+
+func (c *SchemaCodec[T]) Marshal(v T) ([]byte, error) {
+	return c.marshal("", false, v)
+}
+
+func (c *SchemaCodec[T]) MarshalKey(key string, v T) ([]byte, error) {
+	return c.marshal(key, true, v)
+}
+
+func (c *SchemaCodec[T]) Unmarshal(data []byte, v *T) error {
+	return c.unmarshal("", false, data, v)
+}
+
+func (c *SchemaCodec[T]) UnmarshalKey(key string, data []byte, v *T) error {
+	return c.unmarshal(key, true, data, v)
+}
+
+func (c *SchemaCodec[T]) marshal(key string, keyed bool, v T) ([]byte, error) {
+	data, err := marshalInner(c.codec, key, keyed, v)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, 0, len(data)+1+binary.MaxVarintLen32)
+	out = append(out, schemaHeader)
+	out = binary.AppendUvarint(out, uint64(c.version))
+	return append(out, data...), nil
+}
+
+func (c *SchemaCodec[T]) unmarshal(key string, keyed bool, data []byte, v *T) error {
+	var version uint64
+	if len(data) > 0 && data[0] == schemaHeader {
+		var n int
+		if version, n = binary.Uvarint(data[1:]); n <= 0 {
+			return c.unknown("invalid version header")
+		}
+		data = data[1+n:]
+	}
+
+	from := version
+	for ; version < uint64(c.version); version++ {
+		m, ok := c.migrations[uint32(version)]
+		if !ok {
+			return c.unknown(fmt.Sprintf("no migration from version %d", version))
+		}
+		var err error
+		if data, err = m(data); err != nil {
+			return fmt.Errorf("codec: migrate from version %d: %w", version, err)
+		}
+	}
+	if version != uint64(c.version) {
+		return c.unknown(fmt.Sprintf("version %d is newer than %d", version, c.version))
+	}
+
+	if err := unmarshalInner(c.codec, key, keyed, data, v); err != nil {
+		return err
+	}
+	if from != version {
+		c.migrated.Add(1)
+		if c.onMigrate != nil {
+			c.onMigrate(uint32(from), c.version)
+		}
+	}
+	return nil
+}
+
+func (c *SchemaCodec[T]) unknown(reason string) error {
+	if c.discardUnknown {
+		return fmt.Errorf("%w: %w: %s", ErrDiscard, ErrUnknownSchema, reason)
+	}
+	return fmt.Errorf("%w: %s", ErrUnknownSchema, reason)
+}
@@ -0,0 +1,68 @@
+package codec
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type userV3 struct {
+	ID       int
+	FullName string
+	Email    string
+}
+
+func newUserCodec() *SchemaCodec[userV3] {
+	return NewSchemaCodec[userV3](&JsonCodec[userV3]{}, 2).
+		AddMigration(0, MigrateFunc(&JsonCodec[userV1]{}, &JsonCodec[userV2]{}, func(u userV1) (userV2, error) {
+			return userV2{ID: u.ID, Name: u.Name, Email: "unknown"}, nil
+		})).
+		AddMigration(1, MigrateFunc(&JsonCodec[userV2]{}, &JsonCodec[userV3]{}, func(u userV2) (userV3, error) {
+			return userV3{ID: u.ID, FullName: strings.ToUpper(u.Name), Email: u.Email}, nil
+		}))
+}
+
+func TestSchemaCodec(t *testing.T) {
+	c := newUserCodec()
+	var migrations []uint32
+	c.SetOnMigrate(func(from, to uint32) { migrations = append(migrations, from, to) })
+
+	// values of the current version are read as is
+	data, err := c.Marshal(userV3{ID: 1, FullName: "A"})
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{schemaHeader, 2}, data[:2])
+	var u userV3
+	assert.Nil(t, c.Unmarshal(data, &u))
+	assert.Equal(t, userV3{ID: 1, FullName: "A"}, u)
+	assert.Equal(t, uint64(0), c.Migrations())
+
+	// plain values from before the envelope are version 0
+	legacy, _ := (&JsonCodec[userV1]{}).Marshal(userV1{ID: 2, Name: "bob"})
+	assert.Nil(t, c.Unmarshal(legacy, &u))
+	assert.Equal(t, userV3{ID: 2, FullName: "BOB", Email: "unknown"}, u)
+
+	v1, _ := NewSchemaCodec[userV2](&JsonCodec[userV2]{}, 1).Marshal(userV2{ID: 3, Name: "c", Email: "c@x"})
+	assert.Nil(t, c.Unmarshal(v1, &u))
+	assert.Equal(t, userV3{ID: 3, FullName: "C", Email: "c@x"}, u)
+	assert.Equal(t, uint64(2), c.Migrations())
+	assert.Equal(t, []uint32{0, 2, 1, 2}, migrations)
+}
+
+func TestSchemaCodecUnknownVersion(t *testing.T) {
+	newer, _ := NewSchemaCodec[userV3](&JsonCodec[userV3]{}, 3).Marshal(userV3{ID: 1})
+	c := newUserCodec()
+	var u userV3
+	err := c.Unmarshal(newer, &u)
+	assert.ErrorIs(t, err, ErrUnknownSchema)
+	assert.NotErrorIs(t, err, ErrDiscard)
+
+	c.SetDiscardUnknown(true)
+	err = c.Unmarshal(newer, &u)
+	assert.ErrorIs(t, err, ErrUnknownSchema)
+	assert.ErrorIs(t, err, ErrDiscard)
+
+	// a gap in the migrations is unknown as well
+	noMigrations := NewSchemaCodec[userV3](&JsonCodec[userV3]{}, 2)
+	assert.ErrorIs(t, noMigrations.Unmarshal([]byte(`{"ID":1}`), &u), ErrUnknownSchema)
+}
@@ -0,0 +1,95 @@
+package tiercache
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mbeoliero/tiercache/cacher"
+)
+
+// Compute runs an atomic read-modify-write on the key, e.g. to increment a counter or append to a cached list.
+//
+// The first cache level implementing cacher.Computer, such as a RedisCache, is the authority: fn runs there with
+// optimistic concurrency and may be called again if the key changes concurrently. The other cache levels are
+// then invalidated, as concurrent computes could otherwise leave an older value in them; the next read
+// back-populates them from the authority.
+// If no level implements cacher.Computer, fn runs on the topmost cached value under a lock that only
+// serializes Compute calls within this process, and all cache levels are updated.
+//
+// Loader levels are never read or written. Compute returns the value the key holds afterwards.
+func (c *MultiLevelCache[K, V]) Compute(ctx context.Context, key K, fn cacher.ComputeFunc[V], opts ...OptFunc) (V, cacher.Action, error) {
+	o := defaultOpts()
+	for _, opt := range opts {
+		opt(o)
+	}
+	o.skipLoaders = true
+	defer optionsPool.Put(o)
+
+	var (
+		ret       V
+		action    cacher.Action
+		authority = -1
+	)
+	for i, store := range c.stores {
+		mwCtx := cacher.NewContext(ctx, cacher.NewRunInfo(i+1))
+		if c.skipLayer(mwCtx, store, o) {
+			continue
+		}
+		computer, ok := cacher.As[cacher.Computer[K, V]](store)
+		if !ok {
+			continue
+		}
+
+		var err error
+		if ret, action, err = computer.Compute(mwCtx, key, fn); err != nil {
+			return ret, action, fmt.Errorf("cache store idx[%d] Compute error: %w", i, err)
+		}
+		authority = i
+		break
+	}
+
+	if authority < 0 {
+		c.computeMu.Lock()
+		defer c.computeMu.Unlock()
+
+		entries, err := c.MPeek(ctx, []K{key}, opts...)
+		if err != nil {
+			return ret, cacher.ActionKeep, err
+		}
+		entry, found := entries[key]
+		if ret, action = fn(entry.Value, found); action == cacher.ActionKeep {
+			return entry.Value, action, nil
+		}
+	}
+
+	if err := c.applyAction(ctx, key, ret, action, authority); err != nil {
+		return ret, action, err
+	}
+	return ret, action, nil
+}
+
+// applyAction writes the outcome of a Compute to every cache level except the authority,
+// or invalidates them if there is an authority.
+// Levels skipped by the options are updated as well so they don't keep a stale value.
+func (c *MultiLevelCache[K, V]) applyAction(ctx context.Context, key K, value V, action cacher.Action, authority int) error {
+	if action == cacher.ActionKeep {
+		return nil
+	}
+	for i, store := range c.stores {
+		mwCtx := cacher.NewContext(ctx, cacher.NewRunInfo(i+1))
+		if i == authority || cacher.IsLoader(store) {
+			continue
+		}
+
+		var err error
+		if action == cacher.ActionSet && authority < 0 {
+			err = store.MSet(mwCtx, map[K]V{key: value})
+		} else {
+			err = store.MDel(mwCtx, []K{key})
+		}
+		if err != nil {
+			return fmt.Errorf("cache store idx[%d] Compute update error: %s", i, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+package datasource
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Batcher collects the keys requested by concurrent callers within a short wait window, DataLoader style,
+// and fetches them with a single batch call. Each caller gets the result for its own keys.
+//
+//	b := datasource.NewBatcher(fetchUsers.ToDetailed(), 2*time.Millisecond, 100)
+//	ds := datasource.NewDataSourceWithDetailedFetcher(b.Fetch)
+type Batcher[K comparable, V any] struct {
+	fetch    DetailedBatchFetcher[K, V]
+	wait     time.Duration
+	maxBatch int
+
+	mu      sync.Mutex
+	pending *batch[K, V]
+}
+
+type batch[K comparable, V any] struct {
+	keys []K
+	seen map[K]struct{}
+
+	// ctx carries the values of the first caller but not its cancellation, which is tracked per caller
+	ctx        context.Context
+	cancel     context.CancelFunc
+	waiters    int
+	dispatched bool
+	timer      *time.Timer
+
+	done chan struct{}
+	res  *BatchResult[K, V]
+	err  error
+}
+
+// NewBatcher creates a Batcher that waits up to wait for more keys after the first one arrives,
+// or until maxBatch distinct keys are collected. A maxBatch of zero means no limit.
+func NewBatcher[K comparable, V any](fetch DetailedBatchFetcher[K, V], wait time.Duration, maxBatch int) *Batcher[K, V] {
+	return &Batcher[K, V]{
+		fetch:    fetch,
+		wait:     wait,
+		maxBatch: maxBatch,
+	}
+}
+
+// Fetch adds the keys to the pending batch and waits for its result.
+// It has the signature of a DetailedBatchFetcher. When ctx is done the caller stops waiting,
+// and the batch call is cancelled once no caller waits for it anymore.
+func (b *Batcher[K, V]) Fetch(ctx context.Context, keys []K) (*BatchResult[K, V], error) {
+	batches := b.enqueue(ctx, keys)
+
+	for i, bt := range batches {
+		select {
+		case <-bt.done:
+		case <-ctx.Done():
+			for _, left := range batches[i:] {
+				b.leave(left)
+			}
+			return nil, ctx.Err()
+		}
+	}
+
+	ret := &BatchResult[K, V]{Values: make(map[K]V, len(keys))}
+	for _, bt := range batches {
+		if bt.err != nil {
+			return nil, bt.err
+		}
+	}
+	for _, k := range keys {
+		b.collect(ret, batches, k)
+	}
+	return ret, nil
+}
+
+// enqueue adds the keys to the pending batch, starting new batches when it is full,
+// and returns every batch the caller has to wait for.
+func (b *Batcher[K, V]) enqueue(ctx context.Context, keys []K) []*batch[K, V] {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var batches []*batch[K, V]
+	for _, k := range keys {
+		if b.pending == nil {
+			b.pending = b.newBatch(ctx)
+		}
+		bt := b.pending
+		if len(batches) == 0 || batches[len(batches)-1] != bt {
+			bt.waiters++
+			batches = append(batches, bt)
+		}
+		if _, ok := bt.seen[k]; !ok {
+			bt.seen[k] = struct{}{}
+			bt.keys = append(bt.keys, k)
+		}
+		if b.maxBatch > 0 && len(bt.keys) >= b.maxBatch {
+			b.dispatchLocked(bt)
+		}
+	}
+	return batches
+}
+
+func (b *Batcher[K, V]) newBatch(ctx context.Context) *batch[K, V] {
+	bctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	bt := &batch[K, V]{
+		seen:   make(map[K]struct{}),
+		ctx:    bctx,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	bt.timer = time.AfterFunc(b.wait, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.dispatchLocked(bt)
+	})
+	return bt
+}
+
+// dispatchLocked closes the batch for new keys and runs the fetch; b.mu must be held
+func (b *Batcher[K, V]) dispatchLocked(bt *batch[K, V]) {
+	if bt.dispatched {
+		return
+	}
+	bt.dispatched = true
+	bt.timer.Stop()
+	if b.pending == bt {
+		b.pending = nil
+	}
+	if bt.waiters == 0 {
+		// every caller already gave up
+		bt.err = context.Canceled
+		bt.cancel()
+		close(bt.done)
+		return
+	}
+
+	go func() {
+		defer bt.cancel()
+		bt.res, bt.err = b.fetch(bt.ctx, bt.keys)
+		close(bt.done)
+	}()
+}
+
+func (b *Batcher[K, V]) leave(bt *batch[K, V]) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	bt.waiters--
+	if bt.waiters == 0 && bt.dispatched {
+		bt.cancel()
+	}
+}
+
+// collect copies the outcome of key k from the batch that fetched it
+func (b *Batcher[K, V]) collect(ret *BatchResult[K, V], batches []*batch[K, V], k K) {
+	for _, bt := range batches {
+		if _, ok := bt.seen[k]; !ok {
+			continue
+		}
+		if bt.res != nil {
+			if v, ok := bt.res.Values[k]; ok {
+				ret.Values[k] = v
+				return
+			}
+			if err, ok := bt.res.Errors[k]; ok {
+				ret.add(k, err)
+				return
+			}
+		}
+		ret.NotFound = append(ret.NotFound, k)
+		return
+	}
+}
@@ -0,0 +1,64 @@
+package datasource
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatcher(t *testing.T) {
+	var calls atomic.Int32
+	fetch := DetailedBatchFetcher[int, int](func(ctx context.Context, keys []int) (*BatchResult[int, int], error) {
+		calls.Add(1)
+		ret := &BatchResult[int, int]{Values: make(map[int]int)}
+		for _, k := range keys {
+			switch k {
+			case 7:
+				ret.add(k, errors.New("bad row"))
+			case 8:
+				ret.NotFound = append(ret.NotFound, k)
+			default:
+				ret.Values[k] = k * 10
+			}
+		}
+		return ret, nil
+	})
+	b := NewBatcher(fetch, 20*time.Millisecond, 100)
+
+	var wg sync.WaitGroup
+	results := make([]*BatchResult[int, int], 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			res, err := b.Fetch(context.TODO(), []int{i})
+			assert.Nil(t, err)
+			results[i] = res
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), calls.Load())
+	assert.Equal(t, map[int]int{3: 30}, results[3].Values)
+	assert.Equal(t, "bad row", results[7].Errors[7].Error())
+	assert.Equal(t, []int{8}, results[8].NotFound)
+
+	// a full batch is dispatched without waiting for the window
+	b = NewBatcher(fetch, time.Hour, 3)
+	res, err := b.Fetch(context.TODO(), []int{1, 2, 3, 4, 5, 6})
+	assert.Nil(t, err)
+	assert.Len(t, res.Values, 6)
+	assert.Equal(t, int32(3), calls.Load())
+
+	// a cancelled waiter returns immediately
+	b = NewBatcher(fetch, time.Hour, 100)
+	ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Millisecond)
+	defer cancel()
+	_, err = b.Fetch(ctx, []int{1})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
@@ -1,36 +1,59 @@
 package datasource
 
-import "context"
+import (
+	"context"
+
+	"github.com/mbeoliero/tiercache/cacher"
+)
 
 type DataSource[K comparable, V any] struct {
-	batchFetch func(ctx context.Context, keys []K) (map[K]V, error)
+	batchFetch DetailedBatchFetcher[K, V]
+}
+
+func NewDataSource[K comparable, V any](f func(ctx context.Context, keys []K) (map[K]V, error), opts ...AdapterOption) *DataSource[K, V] {
+	return &DataSource[K, V]{
+		batchFetch: BatchFetcher[K, V](f).ToDetailed(opts...),
+	}
 }
 
-func NewDataSource[K comparable, V any](f func(ctx context.Context, keys []K) (map[K]V, error)) *DataSource[K, V] {
+func NewDataSourceWithFetcher[K comparable, V any](f Fetcher[K, V], opts ...AdapterOption) *DataSource[K, V] {
 	return &DataSource[K, V]{
-		batchFetch: f,
+		batchFetch: f.ToDetailedBatchFetcher(opts...),
 	}
 }
 
-func NewDataSourceWithFetcher[K comparable, V any](f Fetcher[K, V]) *DataSource[K, V] {
+func NewDataSourceWithDetailedFetcher[K comparable, V any](f DetailedBatchFetcher[K, V], opts ...AdapterOption) *DataSource[K, V] {
 	return &DataSource[K, V]{
-		batchFetch: f.ToBatchFetcher(),
+		batchFetch: f.Chunked(opts...),
 	}
 }
 
+// MGet fetches the keys from the data source. Keys that failed individually are reported
+// through a *cacher.PartialError, so one bad row doesn't fail the whole batch.
 func (r *DataSource[K, V]) MGet(ctx context.Context, keys []K) (map[K]V, []K, error) {
-	ret, err := r.batchFetch(ctx, keys)
+	res, err := r.batchFetch(ctx, keys)
 	if err != nil {
 		return nil, nil, err
 	}
 
+	ret := res.Values
+	if ret == nil {
+		ret = make(map[K]V)
+	}
 	miss := make([]K, 0)
 	for _, k := range keys {
-		if _, ok := ret[k]; !ok {
-			miss = append(miss, k)
+		if _, ok := ret[k]; ok {
+			continue
+		}
+		if _, failed := res.Errors[k]; failed {
+			continue
 		}
+		miss = append(miss, k)
 	}
 
+	if len(res.Errors) > 0 {
+		return ret, miss, cacher.NewPartialError(res.Errors)
+	}
 	return ret, miss, nil
 }
 
@@ -42,6 +65,11 @@ func (r *DataSource[K, T]) MDel(ctx context.Context, keys []K) error {
 	return nil
 }
 
+// IsLoader marks the DataSource as a loader level, which cache-only reads skip
+func (r *DataSource[K, V]) IsLoader() bool {
+	return true
+}
+
 func (r *DataSource[K, V]) Name() string {
 	return "data_source"
 }
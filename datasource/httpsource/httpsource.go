@@ -0,0 +1,227 @@
+// Package httpsource builds DataSources that load batches from upstream HTTP/JSON services.
+package httpsource
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/mbeoliero/tiercache/codec"
+	"github.com/mbeoliero/tiercache/datasource"
+	"github.com/mbeoliero/tiercache/internal/convert"
+)
+
+const (
+	defaultParam       = "ids"
+	defaultMaxResponse = 10 << 20
+)
+
+// ErrResponseTooLarge is returned when a response body is larger than Config.MaxResponseSize
+var ErrResponseTooLarge = errors.New("httpsource: response too large")
+
+// RequestStyle is how the keys of a batch are sent to the service
+type RequestStyle int
+
+const (
+	// QueryParam sends a GET request with one query parameter per key, e.g. ?ids=1&ids=2
+	QueryParam RequestStyle = iota
+	// PostBody sends a POST request whose body is the encoded list of keys
+	PostBody
+)
+
+// StatusError is returned when the service answers with an unexpected status code
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("httpsource: unexpected status %d", e.StatusCode)
+}
+
+type Config[K comparable, V any] struct {
+	// Client sends the requests, defaults to http.DefaultClient
+	Client *http.Client
+	URL    string
+	Style  RequestStyle
+	// Param is the query parameter carrying the keys in QueryParam style, defaults to "ids"
+	Param string
+	// KeyString formats a key for the request and for matching it in the response
+	KeyString func(K) string
+	// Codec decodes the response body into a map from key string to value, defaults to JSON
+	Codec codec.Codec[map[string]V]
+	// BodyCodec encodes the key strings in PostBody style, defaults to a JSON array
+	BodyCodec codec.Codec[[]string]
+	// ContentType is the Content-Type of the PostBody request body. It defaults to "application/json"
+	// with the default BodyCodec and to "application/octet-stream" with any other one
+	ContentType string
+	// MaxResponseSize is the largest response body read, in bytes, defaults to 10 MiB.
+	// A larger body fails the batch with ErrResponseTooLarge
+	MaxResponseSize int64
+	// Header is sent with every request, in addition to the headers found in the context
+	Header http.Header
+}
+
+type headerKey struct{}
+
+// WithHeader returns a context whose requests carry the given headers, e.g. for auth or tracing
+func WithHeader(ctx context.Context, header http.Header) context.Context {
+	return context.WithValue(ctx, headerKey{}, header)
+}
+
+// HeaderFromContext returns the headers set by WithHeader
+func HeaderFromContext(ctx context.Context) http.Header {
+	header, _ := ctx.Value(headerKey{}).(http.Header)
+	return header
+}
+
+// NewFetcher creates a batch fetcher that loads the keys from the service.
+// Keys missing from the response, or all keys on a 404, are reported as not found; 5xx and other
+// unexpected statuses fail the batch with a *StatusError.
+// Use datasource.WithMaxBatchSize and datasource.WithConcurrency to split large batches.
+func NewFetcher[K comparable, V any](cfg Config[K, V], opts ...datasource.AdapterOption) (datasource.DetailedBatchFetcher[K, V], error) {
+	if cfg.URL == "" {
+		return nil, errors.New("httpsource: URL is required")
+	}
+	if _, err := url.Parse(cfg.URL); err != nil {
+		return nil, fmt.Errorf("httpsource: invalid URL: %w", err)
+	}
+	if cfg.Client == nil {
+		cfg.Client = http.DefaultClient
+	}
+	if cfg.Param == "" {
+		cfg.Param = defaultParam
+	}
+	if cfg.KeyString == nil {
+		cfg.KeyString = func(k K) string { return convert.ToString(k) }
+	}
+	if cfg.Codec == nil {
+		cfg.Codec = &codec.JsonCodec[map[string]V]{}
+	}
+	if cfg.BodyCodec == nil {
+		cfg.BodyCodec = &codec.JsonCodec[[]string]{}
+		if cfg.ContentType == "" {
+			cfg.ContentType = "application/json"
+		}
+	}
+	if cfg.ContentType == "" {
+		cfg.ContentType = "application/octet-stream"
+	}
+	if cfg.MaxResponseSize <= 0 {
+		cfg.MaxResponseSize = defaultMaxResponse
+	}
+
+	s := &source[K, V]{cfg: cfg}
+	return datasource.BatchFetcher[K, V](s.fetch).ToDetailed(opts...), nil
+}
+
+// NewDataSource creates a DataSource backed by NewFetcher
+func NewDataSource[K comparable, V any](cfg Config[K, V], opts ...datasource.AdapterOption) (*datasource.DataSource[K, V], error) {
+	f, err := NewFetcher(cfg, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return datasource.NewDataSourceWithDetailedFetcher(f), nil
+}
+
+type source[K comparable, V any] struct {
+	cfg Config[K, V]
+}
+
+func (s *source[K, V]) fetch(ctx context.Context, keys []K) (map[K]V, error) {
+	ret := make(map[K]V, len(keys))
+	if len(keys) == 0 {
+		return ret, nil
+	}
+
+	byString := make(map[string]K, len(keys))
+	strKeys := make([]string, 0, len(keys))
+	for _, k := range keys {
+		str := s.cfg.KeyString(k)
+		byString[str] = k
+		strKeys = append(strKeys, str)
+	}
+
+	req, err := s.newRequest(ctx, strKeys)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.cfg.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return ret, nil
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil, &StatusError{StatusCode: resp.StatusCode}
+	}
+
+	// read one byte past the limit to tell a body of exactly the limit from a larger one
+	body, err := io.ReadAll(io.LimitReader(resp.Body, s.cfg.MaxResponseSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) > s.cfg.MaxResponseSize {
+		return nil, ErrResponseTooLarge
+	}
+	var values map[string]V
+	if err = s.cfg.Codec.Unmarshal(body, &values); err != nil {
+		return nil, fmt.Errorf("httpsource: decode response: %w", err)
+	}
+	for str, v := range values {
+		if k, ok := byString[str]; ok {
+			ret[k] = v
+		}
+	}
+	return ret, nil
+}
+
+func (s *source[K, V]) newRequest(ctx context.Context, strKeys []string) (*http.Request, error) {
+	var req *http.Request
+	switch s.cfg.Style {
+	case PostBody:
+		body, err := s.cfg.BodyCodec.Marshal(strKeys)
+		if err != nil {
+			return nil, err
+		}
+		if req, err = http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.URL, bytes.NewReader(body)); err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", s.cfg.ContentType)
+	default:
+		u, err := url.Parse(s.cfg.URL)
+		if err != nil {
+			return nil, err
+		}
+		query := u.Query()
+		for _, str := range strKeys {
+			query.Add(s.cfg.Param, str)
+		}
+		u.RawQuery = query.Encode()
+		if req, err = http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil); err != nil {
+			return nil, err
+		}
+	}
+
+	req.Header.Set("Accept", "application/json")
+	for name, values := range s.cfg.Header {
+		for _, v := range values {
+			req.Header.Add(name, v)
+		}
+	}
+	for name, values := range HeaderFromContext(ctx) {
+		req.Header.Del(name)
+		for _, v := range values {
+			req.Header.Add(name, v)
+		}
+	}
+	return req, nil
+}
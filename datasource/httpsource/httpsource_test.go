@@ -0,0 +1,136 @@
+package httpsource
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mbeoliero/tiercache/codec"
+	"github.com/mbeoliero/tiercache/datasource"
+	"github.com/stretchr/testify/assert"
+)
+
+type User struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+var users = map[string]User{"1": {ID: 1, Name: "alice"}, "2": {ID: 2, Name: "bob"}}
+
+func newServer(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/users", func(w http.ResponseWriter, r *http.Request) {
+		var ids []string
+		if r.Method == http.MethodPost {
+			switch r.Header.Get("Content-Type") {
+			case "application/json":
+				_ = json.NewDecoder(r.Body).Decode(&ids)
+			case "text/csv":
+				body, _ := io.ReadAll(r.Body)
+				ids = strings.Split(string(body), ",")
+			default:
+				w.WriteHeader(http.StatusUnsupportedMediaType)
+				return
+			}
+		} else {
+			ids = r.URL.Query()["ids"]
+		}
+		if r.Header.Get("X-Tenant") != "acme" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		ret := make(map[string]User)
+		for _, id := range ids {
+			if u, ok := users[id]; ok {
+				ret[id] = u
+			}
+		}
+		_ = json.NewEncoder(w).Encode(ret)
+	})
+	mux.HandleFunc("/large", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"1":{"id":1,"name":"` + strings.Repeat("a", 100) + `"}}`))
+	})
+	mux.HandleFunc("/missing", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	mux.HandleFunc("/broken", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	})
+	s := httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestHTTPSource(t *testing.T) {
+	s := newServer(t)
+	ctx := WithHeader(context.TODO(), http.Header{"X-Tenant": []string{"acme"}})
+
+	for _, style := range []RequestStyle{QueryParam, PostBody} {
+		ds, err := NewDataSource(Config[int, User]{URL: s.URL + "/users", Style: style})
+		assert.Nil(t, err)
+		found, miss, err := ds.MGet(ctx, []int{1, 2, 3})
+		assert.Nil(t, err)
+		assert.Equal(t, map[int]User{1: users["1"], 2: users["2"]}, found)
+		assert.Equal(t, []int{3}, miss)
+	}
+
+	// the content type follows a custom body codec
+	ds, err := NewDataSource(Config[int, User]{URL: s.URL + "/users", Style: PostBody, BodyCodec: csvCodec{}})
+	assert.Nil(t, err)
+	_, _, err = ds.MGet(ctx, []int{1})
+	assert.ErrorAs(t, err, new(*StatusError))
+	ds, _ = NewDataSource(Config[int, User]{URL: s.URL + "/users", Style: PostBody, BodyCodec: csvCodec{}, ContentType: "text/csv"})
+	found, miss, err := ds.MGet(ctx, []int{1, 2, 3})
+	assert.Nil(t, err)
+	assert.Equal(t, map[int]User{1: users["1"], 2: users["2"]}, found)
+	assert.Equal(t, []int{3}, miss)
+
+	// response bodies are read up to MaxResponseSize
+	ds, _ = NewDataSource(Config[int, User]{URL: s.URL + "/large", MaxResponseSize: 64})
+	_, _, err = ds.MGet(ctx, []int{1})
+	assert.ErrorIs(t, err, ErrResponseTooLarge)
+	ds, _ = NewDataSource(Config[int, User]{URL: s.URL + "/large", MaxResponseSize: 1024})
+	found, _, err = ds.MGet(ctx, []int{1})
+	assert.Nil(t, err)
+	assert.Len(t, found, 1)
+
+	// headers from the context are required by the service
+	ds, _ = NewDataSource(Config[int, User]{URL: s.URL + "/users"})
+	_, _, err = ds.MGet(context.TODO(), []int{1})
+	var statusErr *StatusError
+	assert.True(t, errors.As(err, &statusErr))
+	assert.Equal(t, http.StatusForbidden, statusErr.StatusCode)
+
+	// 404 is not found
+	ds, _ = NewDataSource(Config[int, User]{URL: s.URL + "/missing"})
+	found, miss, err = ds.MGet(ctx, []int{1, 2})
+	assert.Nil(t, err)
+	assert.Empty(t, found)
+	assert.Equal(t, []int{1, 2}, miss)
+
+	// 5xx is an error
+	f, _ := NewFetcher(Config[int, User]{URL: s.URL + "/broken"}, datasource.WithMaxBatchSize(1))
+	res, err := f(ctx, []int{1, 2})
+	assert.Nil(t, err)
+	assert.True(t, errors.As(res.Errors[1], &statusErr))
+	assert.Equal(t, http.StatusBadGateway, statusErr.StatusCode)
+}
+
+// csvCodec encodes keys as a comma separated list
+type csvCodec struct{}
+
+func (csvCodec) Marshal(keys []string) ([]byte, error) {
+	return []byte(strings.Join(keys, ",")), nil
+}
+
+func (csvCodec) Unmarshal(data []byte, keys *[]string) error {
+	*keys = strings.Split(string(data), ",")
+	return nil
+}
+
+var _ codec.Codec[[]string] = csvCodec{}
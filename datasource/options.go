@@ -0,0 +1,112 @@
+package datasource
+
+import (
+	"context"
+	"sync"
+)
+
+// AdapterOption configures how single-key and batch fetchers are adapted and executed.
+type AdapterOption func(*adapterOpts)
+
+type adapterOpts struct {
+	concurrency  int
+	maxBatchSize int
+}
+
+// WithConcurrency limits how many fetcher calls run in parallel.
+// For a Fetcher this is the number of keys fetched at once, for a batch fetcher the number of chunks.
+// Values below 2 run the calls one after another.
+func WithConcurrency(concurrency int) AdapterOption {
+	return func(o *adapterOpts) {
+		o.concurrency = concurrency
+	}
+}
+
+// WithMaxBatchSize splits the keys passed to a batch fetcher into chunks of at most maxBatchSize keys,
+// e.g. a 5,000-key miss becomes ten calls with 500 keys each. Zero disables chunking.
+func WithMaxBatchSize(maxBatchSize int) AdapterOption {
+	return func(o *adapterOpts) {
+		o.maxBatchSize = maxBatchSize
+	}
+}
+
+// WithBatchSizeLimit caps the chunk size at limit, keeping a smaller WithMaxBatchSize whatever the order
+// of the options. It is meant for fetchers with a hard limit, such as the bind parameters of a SQL statement.
+func WithBatchSizeLimit(limit int) AdapterOption {
+	return func(o *adapterOpts) {
+		if o.maxBatchSize <= 0 || o.maxBatchSize > limit {
+			o.maxBatchSize = limit
+		}
+	}
+}
+
+func newAdapterOpts(opts []AdapterOption) *adapterOpts {
+	o := &adapterOpts{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// chunks splits keys into slices of at most size keys
+func chunks[K any](keys []K, size int) [][]K {
+	if size <= 0 || len(keys) <= size {
+		return [][]K{keys}
+	}
+	ret := make([][]K, 0, (len(keys)+size-1)/size)
+	for start := 0; start < len(keys); start += size {
+		ret = append(ret, keys[start:min(start+size, len(keys))])
+	}
+	return ret
+}
+
+// runLimited calls fn for every index in [0, n) with at most concurrency calls running at once.
+// It stops starting new calls once a call fails or ctx is done, and returns the first error.
+func runLimited(ctx context.Context, n, concurrency int, fn func(ctx context.Context, i int) error) error {
+	if concurrency <= 1 || n <= 1 {
+		for i := 0; i < n; i++ {
+			if err := fn(ctx, i); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+	fail := func(err error) {
+		once.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+
+	sem := make(chan struct{}, concurrency)
+	for i := 0; i < n; i++ {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if err := ctx.Err(); err != nil {
+			// keeps the error of a failed call, otherwise reports the cancellation of the caller
+			fail(err)
+			break
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if err := fn(ctx, i); err != nil {
+				fail(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	return firstErr
+}
@@ -0,0 +1,143 @@
+// Package sqlsource builds DataSources on top of database/sql for the common
+// "SELECT ... WHERE id IN (...)" pattern.
+package sqlsource
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mbeoliero/tiercache/datasource"
+)
+
+// KeysMarker is replaced in Config.Query by the placeholder list of the keys
+const KeysMarker = "{keys}"
+
+const defaultMaxParams = 999
+
+// PlaceholderStyle is the bind parameter syntax of the driver
+type PlaceholderStyle int
+
+const (
+	// Question uses "?" placeholders (MySQL, SQLite)
+	Question PlaceholderStyle = iota
+	// Dollar uses numbered "$n" placeholders (PostgreSQL)
+	Dollar
+)
+
+type Config[K comparable, V any] struct {
+	DB *sql.DB
+	// Query is the statement template, KeysMarker is replaced by the placeholders of the keys, e.g.
+	// "SELECT id, name FROM users WHERE id IN ({keys})"
+	Query string
+	// Args are bound before the keys, for placeholders that appear in Query ahead of KeysMarker
+	Args []any
+	// KeyArg converts a key into the argument bound to its placeholder. Defaults to the key itself.
+	KeyArg func(K) any
+	// Scan reads the key and the value of the current row
+	Scan func(*sql.Rows) (K, V, error)
+	// Placeholder is the bind parameter syntax of the driver
+	Placeholder PlaceholderStyle
+	// MaxParams is the driver's limit of bind parameters per statement, defaults to 999.
+	// Larger batches are split into several queries.
+	MaxParams int
+}
+
+// NewFetcher creates a batch fetcher that loads the keys with IN queries, chunked so every query stays
+// within the parameter limit. Keys without a row are reported as not found.
+// Use datasource.WithConcurrency to run the chunks in parallel; a datasource.WithMaxBatchSize below the limit is kept.
+func NewFetcher[K comparable, V any](cfg Config[K, V], opts ...datasource.AdapterOption) (datasource.DetailedBatchFetcher[K, V], error) {
+	if cfg.DB == nil {
+		return nil, errors.New("sqlsource: DB is required")
+	}
+	if cfg.Scan == nil {
+		return nil, errors.New("sqlsource: Scan is required")
+	}
+	if strings.Count(cfg.Query, KeysMarker) != 1 {
+		return nil, fmt.Errorf("sqlsource: query must contain %s exactly once", KeysMarker)
+	}
+	if cfg.KeyArg == nil {
+		cfg.KeyArg = func(k K) any { return k }
+	}
+	if cfg.MaxParams <= 0 {
+		cfg.MaxParams = defaultMaxParams
+	}
+	chunkSize := cfg.MaxParams - len(cfg.Args)
+	if chunkSize <= 0 {
+		return nil, errors.New("sqlsource: Args exceed MaxParams")
+	}
+
+	s := &source[K, V]{cfg: cfg}
+	opts = append(opts, datasource.WithBatchSizeLimit(chunkSize))
+	return datasource.BatchFetcher[K, V](s.fetch).ToDetailed(opts...), nil
+}
+
+// NewDataSource creates a DataSource backed by NewFetcher
+func NewDataSource[K comparable, V any](cfg Config[K, V], opts ...datasource.AdapterOption) (*datasource.DataSource[K, V], error) {
+	f, err := NewFetcher(cfg, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return datasource.NewDataSourceWithDetailedFetcher(f), nil
+}
+
+type source[K comparable, V any] struct {
+	cfg Config[K, V]
+}
+
+func (s *source[K, V]) fetch(ctx context.Context, keys []K) (map[K]V, error) {
+	ret := make(map[K]V, len(keys))
+	if len(keys) == 0 {
+		return ret, nil
+	}
+
+	want := make(map[K]struct{}, len(keys))
+	args := make([]any, 0, len(s.cfg.Args)+len(keys))
+	args = append(args, s.cfg.Args...)
+	for _, k := range keys {
+		want[k] = struct{}{}
+		args = append(args, s.cfg.KeyArg(k))
+	}
+	query := strings.Replace(s.cfg.Query, KeysMarker, placeholders(s.cfg.Placeholder, len(s.cfg.Args)+1, len(keys)), 1)
+
+	rows, err := s.cfg.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		k, v, err := s.cfg.Scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		// rows for keys that weren't asked for, e.g. due to type coercion in the database, are ignored
+		if _, ok := want[k]; ok {
+			ret[k] = v
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// placeholders builds a list of n placeholders, numbering "$n" style ones from start
+func placeholders(style PlaceholderStyle, start, n int) string {
+	var sb strings.Builder
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		if style == Dollar {
+			sb.WriteByte('$')
+			sb.WriteString(strconv.Itoa(start + i))
+		} else {
+			sb.WriteByte('?')
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,187 @@
+package sqlsource
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/mbeoliero/tiercache/datasource"
+	"github.com/stretchr/testify/assert"
+)
+
+// stubDriver serves a fixed users table and records the queries it receives
+type stubDriver struct {
+	mu      sync.Mutex
+	queries []string
+	args    [][]driver.Value
+	users   map[int64]string
+}
+
+func (d *stubDriver) Open(name string) (driver.Conn, error) {
+	return &stubConn{d: d}, nil
+}
+
+type stubConn struct {
+	d *stubDriver
+}
+
+func (c *stubConn) Prepare(query string) (driver.Stmt, error) {
+	return &stubStmt{d: c.d, query: query}, nil
+}
+
+func (c *stubConn) Close() error {
+	return nil
+}
+
+func (c *stubConn) Begin() (driver.Tx, error) {
+	return nil, fmt.Errorf("not supported")
+}
+
+type stubStmt struct {
+	d     *stubDriver
+	query string
+}
+
+func (s *stubStmt) Close() error {
+	return nil
+}
+
+func (s *stubStmt) NumInput() int {
+	return -1
+}
+
+func (s *stubStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return nil, fmt.Errorf("not supported")
+}
+
+func (s *stubStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+	s.d.queries = append(s.d.queries, s.query)
+	s.d.args = append(s.d.args, args)
+
+	rows := &stubRows{}
+	for _, arg := range args {
+		id, ok := arg.(int64)
+		if !ok {
+			continue
+		}
+		if name, ok := s.d.users[id]; ok {
+			rows.data = append(rows.data, []driver.Value{id, name})
+		}
+	}
+	return rows, nil
+}
+
+type stubRows struct {
+	data [][]driver.Value
+	pos  int
+}
+
+func (r *stubRows) Columns() []string {
+	return []string{"id", "name"}
+}
+
+func (r *stubRows) Close() error {
+	return nil
+}
+
+func (r *stubRows) Next(dest []driver.Value) error {
+	if r.pos >= len(r.data) {
+		return io.EOF
+	}
+	copy(dest, r.data[r.pos])
+	r.pos++
+	return nil
+}
+
+// stubConnector opens the stub without registering it, so tests can run repeatedly
+type stubConnector struct {
+	d *stubDriver
+}
+
+func (c stubConnector) Connect(context.Context) (driver.Conn, error) { return c.d.Open("") }
+func (c stubConnector) Driver() driver.Driver                        { return c.d }
+
+func openStub(t *testing.T) (*sql.DB, *stubDriver) {
+	d := &stubDriver{users: map[int64]string{1: "alice", 2: "bob", 3: "carol", 4: "dave", 5: "erin"}}
+	db := sql.OpenDB(stubConnector{d: d})
+	t.Cleanup(func() { _ = db.Close() })
+	return db, d
+}
+
+func scanUser(rows *sql.Rows) (int, string, error) {
+	var id int
+	var name string
+	err := rows.Scan(&id, &name)
+	return id, name, err
+}
+
+func TestDollarPlaceholders(t *testing.T) {
+	db, d := openStub(t)
+	ds, err := NewDataSource(Config[int, string]{
+		DB:          db,
+		Query:       "SELECT id, name FROM users WHERE tenant = $1 AND id IN ({keys})",
+		Args:        []any{"acme"},
+		Scan:        scanUser,
+		Placeholder: Dollar,
+		MaxParams:   3,
+	})
+	assert.Nil(t, err)
+
+	found, miss, err := ds.MGet(context.TODO(), []int{1, 2, 3, 4, 9})
+	assert.Nil(t, err)
+	assert.Equal(t, map[int]string{1: "alice", 2: "bob", 3: "carol", 4: "dave"}, found)
+	assert.Equal(t, []int{9}, miss)
+
+	// two parameters per query are left for keys after the tenant argument
+	assert.Equal(t, []string{
+		"SELECT id, name FROM users WHERE tenant = $1 AND id IN ($2, $3)",
+		"SELECT id, name FROM users WHERE tenant = $1 AND id IN ($2, $3)",
+		"SELECT id, name FROM users WHERE tenant = $1 AND id IN ($2)",
+	}, d.queries)
+	assert.Equal(t, []driver.Value{"acme", int64(1), int64(2)}, d.args[0])
+}
+
+func TestQuestionPlaceholders(t *testing.T) {
+	db, d := openStub(t)
+	f, err := NewFetcher(Config[int, string]{
+		DB:    db,
+		Query: "SELECT id, name FROM users WHERE id IN ({keys})",
+		Scan:  scanUser,
+	})
+	assert.Nil(t, err)
+
+	res, err := f(context.TODO(), []int{5, 6})
+	assert.Nil(t, err)
+	assert.Equal(t, map[int]string{5: "erin"}, res.Values)
+	assert.Equal(t, []int{6}, res.NotFound)
+	assert.Equal(t, []string{"SELECT id, name FROM users WHERE id IN (?, ?)"}, d.queries)
+
+	_, err = NewFetcher(Config[int, string]{DB: db, Query: "SELECT 1", Scan: scanUser})
+	assert.NotNil(t, err)
+}
+
+func TestMaxBatchSize(t *testing.T) {
+	// a batch size below the parameter limit is kept, a larger one is capped
+	for _, tc := range []struct {
+		maxBatchSize int
+		queries      int
+	}{{1, 4}, {100, 2}} {
+		db, d := openStub(t)
+		f, err := NewFetcher(Config[int, string]{
+			DB:        db,
+			Query:     "SELECT id, name FROM users WHERE id IN ({keys})",
+			Scan:      scanUser,
+			MaxParams: 2,
+		}, datasource.WithMaxBatchSize(tc.maxBatchSize))
+		assert.Nil(t, err)
+		_, err = f(context.TODO(), []int{1, 2, 3, 4})
+		assert.Nil(t, err)
+		assert.Len(t, d.queries, tc.queries, "max batch size %d", tc.maxBatchSize)
+	}
+}
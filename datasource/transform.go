@@ -1,21 +1,171 @@
 package datasource
 
-import "context"
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/mbeoliero/tiercache/cacher"
+)
+
+// ErrNotFound can be returned by a Fetcher to report that the key does not exist.
+// The key is then treated as a miss instead of an error.
+var ErrNotFound = errors.New("datasource: not found")
 
 type Fetcher[K comparable, V any] func(ctx context.Context, key K) (V, error)
 
 type BatchFetcher[K comparable, V any] func(ctx context.Context, keys []K) (map[K]V, error)
 
-func (f Fetcher[K, V]) ToBatchFetcher() BatchFetcher[K, V] {
+// BatchResult is the outcome of a DetailedBatchFetcher call.
+// Keys that are in neither Values, NotFound nor Errors are treated as not found.
+type BatchResult[K comparable, V any] struct {
+	Values   map[K]V
+	NotFound []K
+	Errors   map[K]error
+}
+
+// DetailedBatchFetcher fetches a batch of keys and reports the outcome of every key.
+// The returned error is reserved for failures of the whole batch.
+type DetailedBatchFetcher[K comparable, V any] func(ctx context.Context, keys []K) (*BatchResult[K, V], error)
+
+// ToBatchFetcher calls the fetcher for every key. Keys failing with ErrNotFound are left out of the result,
+// any other error fails the whole batch. Use WithConcurrency to fetch several keys in parallel.
+func (f Fetcher[K, V]) ToBatchFetcher(opts ...AdapterOption) BatchFetcher[K, V] {
+	o := newAdapterOpts(opts)
 	return func(ctx context.Context, keys []K) (map[K]V, error) {
+		var mu sync.Mutex
 		ret := make(map[K]V)
+		err := runLimited(ctx, len(keys), o.concurrency, func(ctx context.Context, i int) error {
+			v, err := f(ctx, keys[i])
+			if err != nil {
+				if errors.Is(err, ErrNotFound) {
+					return nil
+				}
+				return err
+			}
+			mu.Lock()
+			ret[keys[i]] = v
+			mu.Unlock()
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		return ret, nil
+	}
+}
+
+// ToDetailedBatchFetcher calls the fetcher for every key and keeps the outcome of each one,
+// so a failing key doesn't throw away the others. Use WithConcurrency to fetch several keys in parallel.
+func (f Fetcher[K, V]) ToDetailedBatchFetcher(opts ...AdapterOption) DetailedBatchFetcher[K, V] {
+	o := newAdapterOpts(opts)
+	return func(ctx context.Context, keys []K) (*BatchResult[K, V], error) {
+		var mu sync.Mutex
+		ret := &BatchResult[K, V]{Values: make(map[K]V, len(keys))}
+		err := runLimited(ctx, len(keys), o.concurrency, func(ctx context.Context, i int) error {
+			v, err := f(ctx, keys[i])
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				ret.add(keys[i], err)
+				return nil
+			}
+			ret.Values[keys[i]] = v
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		return ret, nil
+	}
+}
+
+// ToDetailed adapts the batch fetcher to a DetailedBatchFetcher, reporting the keys it didn't return as not found.
+// The fetcher reports keys that failed individually by returning the values it did fetch together with
+// a *cacher.PartialError; those keys end up in Errors, or in NotFound for ErrNotFound, instead of being misses.
+// With WithMaxBatchSize the keys are fetched in chunks, and a failing chunk only fails its own keys.
+func (f BatchFetcher[K, V]) ToDetailed(opts ...AdapterOption) DetailedBatchFetcher[K, V] {
+	detailed := DetailedBatchFetcher[K, V](func(ctx context.Context, keys []K) (*BatchResult[K, V], error) {
+		values, err := f(ctx, keys)
+		var partialErr *cacher.PartialError[K]
+		if err != nil && !errors.As(err, &partialErr) {
+			return nil, err
+		}
+		ret := &BatchResult[K, V]{Values: values}
+		if ret.Values == nil {
+			ret.Values = make(map[K]V)
+		}
+		var failed map[K]error
+		if partialErr != nil {
+			failed = partialErr.Errors
+			for k, err := range failed {
+				ret.add(k, err)
+			}
+		}
 		for _, k := range keys {
-			v, err := f(ctx, k)
+			if _, ok := ret.Values[k]; ok {
+				continue
+			}
+			if _, ok := failed[k]; !ok {
+				ret.NotFound = append(ret.NotFound, k)
+			}
+		}
+		return ret, nil
+	})
+	return detailed.Chunked(opts...)
+}
+
+// Chunked splits the keys into chunks of at most WithMaxBatchSize keys and fetches up to WithConcurrency chunks
+// in parallel. The chunk results are merged; a chunk that fails as a whole reports its error for each of its keys.
+func (f DetailedBatchFetcher[K, V]) Chunked(opts ...AdapterOption) DetailedBatchFetcher[K, V] {
+	o := newAdapterOpts(opts)
+	if o.maxBatchSize <= 0 {
+		return f
+	}
+	return func(ctx context.Context, keys []K) (*BatchResult[K, V], error) {
+		// a single chunk takes the same path, so errors have the same shape whatever the number of keys
+		parts := chunks(keys, o.maxBatchSize)
+
+		var mu sync.Mutex
+		ret := &BatchResult[K, V]{Values: make(map[K]V, len(keys))}
+		err := runLimited(ctx, len(parts), o.concurrency, func(ctx context.Context, i int) error {
+			res, err := f(ctx, parts[i])
+			mu.Lock()
+			defer mu.Unlock()
 			if err != nil {
-				return nil, err
+				for _, k := range parts[i] {
+					ret.add(k, err)
+				}
+				return nil
 			}
-			ret[k] = v
+			ret.merge(res)
+			return nil
+		})
+		if err != nil {
+			return nil, err
 		}
 		return ret, nil
 	}
 }
+
+// add records the error of a single key, treating ErrNotFound as not found
+func (r *BatchResult[K, V]) add(k K, err error) {
+	if errors.Is(err, ErrNotFound) {
+		r.NotFound = append(r.NotFound, k)
+		return
+	}
+	if r.Errors == nil {
+		r.Errors = make(map[K]error)
+	}
+	r.Errors[k] = err
+}
+
+func (r *BatchResult[K, V]) merge(other *BatchResult[K, V]) {
+	for k, v := range other.Values {
+		r.Values[k] = v
+	}
+	r.NotFound = append(r.NotFound, other.NotFound...)
+	for k, err := range other.Errors {
+		r.add(k, err)
+	}
+}
@@ -0,0 +1,75 @@
+package datasource
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/mbeoliero/tiercache/cacher"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChunkedBatchFetcher(t *testing.T) {
+	var mu sync.Mutex
+	var sizes []int
+	fetch := BatchFetcher[int, int](func(ctx context.Context, keys []int) (map[int]int, error) {
+		mu.Lock()
+		sizes = append(sizes, len(keys))
+		mu.Unlock()
+		if keys[0] == 500 {
+			return nil, errors.New("chunk failed")
+		}
+		ret := make(map[int]int, len(keys))
+		for _, k := range keys {
+			if k%100 != 99 {
+				ret[k] = k * 2
+			}
+		}
+		return ret, nil
+	}).ToDetailed(WithMaxBatchSize(250), WithConcurrency(3))
+
+	keys := make([]int, 1000)
+	for i := range keys {
+		keys[i] = i
+	}
+	res, err := fetch(context.TODO(), keys)
+	assert.Nil(t, err)
+
+	sort.Ints(sizes)
+	assert.Equal(t, []int{250, 250, 250, 250}, sizes)
+	// the failed chunk only fails its own keys
+	assert.Len(t, res.Errors, 250)
+	assert.Equal(t, "chunk failed", res.Errors[600].Error())
+	assert.Len(t, res.NotFound, 8)
+	assert.Len(t, res.Values, 742)
+	assert.Equal(t, 20, res.Values[10])
+
+	// a failing batch that fits in one chunk fails its keys the same way
+	res, err = fetch(context.TODO(), []int{500, 501})
+	assert.Nil(t, err)
+	assert.Len(t, res.Errors, 2)
+	assert.Equal(t, "chunk failed", res.Errors[501].Error())
+}
+
+func TestBatchFetcherPartialError(t *testing.T) {
+	fetch := BatchFetcher[int, int](func(ctx context.Context, keys []int) (map[int]int, error) {
+		return map[int]int{1: 1}, cacher.NewPartialError(map[int]error{2: errors.New("bad row"), 3: ErrNotFound})
+	})
+
+	res, err := fetch.ToDetailed()(context.TODO(), []int{1, 2, 3, 4})
+	assert.Nil(t, err)
+	assert.Equal(t, map[int]int{1: 1}, res.Values)
+	assert.EqualError(t, res.Errors[2], "bad row")
+	assert.Len(t, res.Errors, 1)
+	assert.ElementsMatch(t, []int{3, 4}, res.NotFound)
+
+	// the data source reports the failed key instead of a miss
+	found, miss, err := NewDataSource(fetch).MGet(context.TODO(), []int{1, 2, 3, 4})
+	var partialErr *cacher.PartialError[int]
+	assert.ErrorAs(t, err, &partialErr)
+	assert.Equal(t, []int{2}, partialErr.Keys())
+	assert.Equal(t, map[int]int{1: 1}, found)
+	assert.Equal(t, []int{3, 4}, miss)
+}
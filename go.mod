@@ -4,6 +4,7 @@ go 1.24.5
 
 require (
 	github.com/alicebob/miniredis/v2 v2.35.0
+	github.com/cespare/xxhash/v2 v2.3.0
 	github.com/json-iterator/go v1.1.12
 	github.com/maypok86/otter/v2 v2.2.1
 	github.com/redis/go-redis/v9 v9.17.0
@@ -11,7 +12,6 @@ require (
 )
 
 require (
-	github.com/cespare/xxhash/v2 v2.3.0 // indirect
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
 	github.com/modern-go/concurrent v0.0.0-20180228061459-e0a39a4cb421 // indirect
@@ -0,0 +1,156 @@
+package hedgecache
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/mbeoliero/tiercache/cacher"
+)
+
+const defaultHedgeDelay = 10 * time.Millisecond
+
+// HedgedCache combines a primary store and its read replicas into one logical cache level.
+// MGet is sent to the primary first; if no answer arrives within the hedge delay it is also sent
+// to a replica, and the first successful answer wins. Writes and deletes go to the primary only.
+type HedgedCache[K comparable, V any] struct {
+	primary  cacher.Interface[K, V]
+	replicas []cacher.Interface[K, V]
+	delay    time.Duration
+	tracker  *latencyTracker
+	next     atomic.Uint64
+}
+
+func NewHedgedCache[K comparable, V any](primary cacher.Interface[K, V], replicas ...cacher.Interface[K, V]) *HedgedCache[K, V] {
+	return &HedgedCache[K, V]{
+		primary:  primary,
+		replicas: replicas,
+		delay:    defaultHedgeDelay,
+	}
+}
+
+// SetHedgeDelay sets the fixed time to wait for an answer before a replica is queried as well.
+// When a percentile is configured it is used until enough latencies have been observed.
+func (h *HedgedCache[K, V]) SetHedgeDelay(delay time.Duration) *HedgedCache[K, V] {
+	h.delay = delay
+	return h
+}
+
+// SetHedgePercentile derives the hedge delay from the observed latencies of successful reads,
+// e.g. 0.95 hedges requests that are slower than the p95 of the last window reads.
+// A primary read cancelled because a replica answered first counts with the time it ran,
+// so slow primary reads keep raising the estimate.
+func (h *HedgedCache[K, V]) SetHedgePercentile(percentile float64, window int) *HedgedCache[K, V] {
+	h.tracker = newLatencyTracker(percentile, window)
+	return h
+}
+
+type mgetResult[K comparable, V any] struct {
+	found   map[K]V
+	missing []K
+	err     error
+}
+
+func (h *HedgedCache[K, V]) MGet(ctx context.Context, keys []K) (map[K]V, []K, error) {
+	if len(h.replicas) == 0 {
+		return h.primary.MGet(ctx, keys)
+	}
+
+	// cancelling the context on return stops the attempts that lost the race
+	parent := ctx
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stores := h.attemptOrder()
+	results := make(chan mgetResult[K, V], len(stores))
+	launch := func(i int) {
+		go func() {
+			start := time.Now()
+			found, missing, err := stores[i].MGet(ctx, keys)
+			// the primary started first, so when it lost the race it took at least as long as it ran;
+			// a replica cancelled early says nothing about its latency
+			lost := i == 0 && ctx.Err() != nil && parent.Err() == nil
+			if (err == nil || lost) && h.tracker != nil {
+				h.tracker.observe(time.Since(start))
+			}
+			results <- mgetResult[K, V]{found: found, missing: missing, err: err}
+		}()
+	}
+
+	launch(0)
+	launched, inflight := 1, 1
+	timer := time.NewTimer(h.hedgeDelay())
+	defer timer.Stop()
+
+	var lastErr error
+	for {
+		select {
+		case res := <-results:
+			inflight--
+			// a partial error is still an answer for the keys that didn't fail
+			var partialErr *cacher.PartialError[K]
+			if res.err == nil || errors.As(res.err, &partialErr) {
+				return res.found, res.missing, res.err
+			}
+			lastErr = res.err
+			// an attempt failed: try the next replica right away instead of waiting for the delay
+			if launched < len(stores) {
+				launch(launched)
+				launched++
+				inflight++
+				continue
+			}
+			if inflight == 0 {
+				return nil, nil, lastErr
+			}
+		case <-timer.C:
+			if launched < len(stores) {
+				launch(launched)
+				launched++
+				inflight++
+				timer.Reset(h.hedgeDelay())
+			}
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		}
+	}
+}
+
+func (h *HedgedCache[K, V]) MSet(ctx context.Context, entities map[K]V) error {
+	return h.primary.MSet(ctx, entities)
+}
+
+func (h *HedgedCache[K, V]) MDel(ctx context.Context, keys []K) error {
+	return h.primary.MDel(ctx, keys)
+}
+
+// Name returns the name of the primary store, as the replicas form the same logical level.
+func (h *HedgedCache[K, V]) Name() string {
+	return h.primary.Name()
+}
+
+// Unwrap returns the primary, which receives the writes, so cacher.As finds its capabilities
+func (h *HedgedCache[K, V]) Unwrap() cacher.Interface[K, V] {
+	return h.primary
+}
+
+// attemptOrder returns the primary followed by the replicas, rotated so hedged load is spread across them.
+func (h *HedgedCache[K, V]) attemptOrder() []cacher.Interface[K, V] {
+	stores := make([]cacher.Interface[K, V], 0, len(h.replicas)+1)
+	stores = append(stores, h.primary)
+	start := int(h.next.Add(1) % uint64(len(h.replicas)))
+	for i := 0; i < len(h.replicas); i++ {
+		stores = append(stores, h.replicas[(start+i)%len(h.replicas)])
+	}
+	return stores
+}
+
+func (h *HedgedCache[K, V]) hedgeDelay() time.Duration {
+	if h.tracker != nil {
+		if d, ok := h.tracker.percentile(); ok {
+			return d
+		}
+	}
+	return h.delay
+}
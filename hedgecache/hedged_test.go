@@ -0,0 +1,113 @@
+package hedgecache
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeStore struct {
+	name  string
+	delay time.Duration
+	err   error
+	data  map[string]string
+	calls atomic.Int32
+	sets  atomic.Int32
+}
+
+func (f *fakeStore) Name() string {
+	return f.name
+}
+
+func (f *fakeStore) MGet(ctx context.Context, keys []string) (map[string]string, []string, error) {
+	f.calls.Add(1)
+	select {
+	case <-time.After(f.delay):
+	case <-ctx.Done():
+		return nil, nil, ctx.Err()
+	}
+	if f.err != nil {
+		return nil, nil, f.err
+	}
+	ret := make(map[string]string)
+	miss := make([]string, 0)
+	for _, k := range keys {
+		if v, ok := f.data[k]; ok {
+			ret[k] = v
+		} else {
+			miss = append(miss, k)
+		}
+	}
+	return ret, miss, nil
+}
+
+func (f *fakeStore) MSet(ctx context.Context, entities map[string]string) error {
+	f.sets.Add(1)
+	return nil
+}
+
+func (f *fakeStore) MDel(ctx context.Context, keys []string) error {
+	return nil
+}
+
+func TestHedgedCache(t *testing.T) {
+	ctx := context.TODO()
+
+	// fast primary: replica is never asked
+	primary := &fakeStore{name: "primary", data: map[string]string{"k": "p"}}
+	replica := &fakeStore{name: "replica", data: map[string]string{"k": "r"}}
+	h := NewHedgedCache[string, string](primary, replica).SetHedgeDelay(50 * time.Millisecond)
+	ret, _, err := h.MGet(ctx, []string{"k"})
+	assert.Nil(t, err)
+	assert.Equal(t, "p", ret["k"])
+	assert.Equal(t, int32(0), replica.calls.Load())
+
+	// slow primary: the hedged replica answers first
+	primary = &fakeStore{name: "primary", delay: time.Second, data: map[string]string{"k": "p"}}
+	replica = &fakeStore{name: "replica", data: map[string]string{"k": "r"}}
+	h = NewHedgedCache[string, string](primary, replica).SetHedgeDelay(10 * time.Millisecond)
+	start := time.Now()
+	ret, _, err = h.MGet(ctx, []string{"k"})
+	assert.Nil(t, err)
+	assert.Equal(t, "r", ret["k"])
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+
+	// failing primary: the replica is tried without waiting for the delay
+	primary = &fakeStore{name: "primary", err: errors.New("down")}
+	h = NewHedgedCache[string, string](primary, replica).SetHedgeDelay(time.Second)
+	start = time.Now()
+	ret, _, err = h.MGet(ctx, []string{"k"})
+	assert.Nil(t, err)
+	assert.Equal(t, "r", ret["k"])
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+
+	// writes only go to the primary
+	assert.Nil(t, h.MSet(ctx, map[string]string{"k": "v"}))
+	assert.Equal(t, int32(1), primary.sets.Load())
+	assert.Equal(t, int32(0), replica.sets.Load())
+	assert.Equal(t, "primary", h.Name())
+	assert.Equal(t, primary, h.Unwrap())
+}
+
+func TestHedgePercentileCountsLostPrimaryReads(t *testing.T) {
+	ctx := context.TODO()
+	primary := &fakeStore{name: "primary", delay: time.Hour}
+	replica := &fakeStore{name: "replica", delay: 5 * time.Millisecond}
+	h := NewHedgedCache[string, string](primary, replica).SetHedgeDelay(5*time.Millisecond).SetHedgePercentile(0.9, minSamples)
+
+	// every read is won by the replica after about 10ms; the cancelled primary reads count as well
+	for range minSamples {
+		_, _, err := h.MGet(ctx, []string{"k"})
+		assert.Nil(t, err)
+	}
+	assert.Eventually(t, func() bool {
+		_, ok := h.tracker.percentile()
+		return ok
+	}, time.Second, time.Millisecond)
+	d, _ := h.tracker.percentile()
+	assert.GreaterOrEqual(t, d, 10*time.Millisecond)
+}
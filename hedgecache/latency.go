@@ -0,0 +1,65 @@
+package hedgecache
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// minSamples is the number of observations needed before the percentile is trusted
+const minSamples = 20
+
+// latencyTracker keeps a sliding window of latencies and the percentile computed from it.
+type latencyTracker struct {
+	mu      sync.Mutex
+	p       float64
+	samples []time.Duration
+	pos     int
+	filled  bool
+	cached  time.Duration
+	dirty   int
+}
+
+func newLatencyTracker(percentile float64, window int) *latencyTracker {
+	if window < minSamples {
+		window = minSamples
+	}
+	return &latencyTracker{
+		p:       min(max(percentile, 0), 1),
+		samples: make([]time.Duration, window),
+	}
+}
+
+func (t *latencyTracker) observe(d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.samples[t.pos] = d
+	t.pos++
+	if t.pos == len(t.samples) {
+		t.pos = 0
+		t.filled = true
+	}
+	t.dirty++
+}
+
+func (t *latencyTracker) percentile() (time.Duration, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	n := t.pos
+	if t.filled {
+		n = len(t.samples)
+	}
+	if n < minSamples {
+		return 0, false
+	}
+	// recompute only after a batch of new observations to keep the read path cheap
+	if t.cached == 0 || t.dirty >= minSamples {
+		sorted := slices.Clone(t.samples[:n])
+		slices.Sort(sorted)
+		t.cached = sorted[int(t.p*float64(n-1))]
+		t.dirty = 0
+	}
+	return t.cached, true
+}
@@ -0,0 +1,77 @@
+package tiercache
+
+import (
+	"context"
+
+	"github.com/mbeoliero/tiercache/cacher"
+	"github.com/mbeoliero/tiercache/datasource"
+)
+
+// GetOrLoad gets the key from the cache levels and calls loader only if none of them has it.
+// Loader levels such as a DataSource are skipped, so one cache can serve lookups that need different loaders.
+// A loaded value is back-populated into the cache levels. The loader may return datasource.ErrNotFound
+// for a missing key.
+func (c *MultiLevelCache[K, V]) GetOrLoad(ctx context.Context, key K, loader datasource.Fetcher[K, V], opts ...OptFunc) (V, bool, error) {
+	ret, err := c.MGetOrLoad(ctx, []K{key}, loader.ToBatchFetcher(), opts...)
+	if err != nil {
+		var zero V
+		return zero, false, err
+	}
+	val, ok := ret[key]
+	return val, ok, nil
+}
+
+// MGetOrLoad gets the keys from the cache levels and calls loader once for the keys none of them has.
+// Loader levels such as a DataSource are skipped. The loaded values are back-populated into the cache levels.
+func (c *MultiLevelCache[K, V]) MGetOrLoad(ctx context.Context, keys []K, loader datasource.BatchFetcher[K, V], opts ...OptFunc) (map[K]V, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	o := defaultOpts()
+	for _, opt := range opts {
+		opt(o)
+	}
+	o.skipLoaders = true
+	defer optionsPool.Put(o)
+
+	found, missing, err := c.mGetRecursive(ctx, keys, 0, o, &MGetResult[K, V]{})
+	if err != nil {
+		return nil, err
+	}
+	if len(missing) == 0 {
+		return found, nil
+	}
+
+	loaded, err := loader(ctx, missing)
+	if err != nil {
+		return nil, err
+	}
+	items := make(map[K]V, len(missing))
+	for _, k := range missing {
+		if v, ok := loaded[k]; ok {
+			items[k] = v
+			found[k] = v
+		}
+	}
+	c.backPopulate(ctx, items, o)
+
+	return found, nil
+}
+
+// backPopulate writes items into every cache level that takes part in the read, ignoring write errors
+// just like the back-population of mGetRecursive.
+func (c *MultiLevelCache[K, V]) backPopulate(ctx context.Context, items map[K]V, opts *cacheOpts) {
+	if len(items) == 0 {
+		return
+	}
+	for i, store := range c.stores {
+		mwCtx := cacher.NewContext(ctx, cacher.NewRunInfo(i+1))
+		if cacher.IsLoader(store) || c.skipLayer(mwCtx, store, opts) {
+			continue
+		}
+		setCtx, cancel := c.layerContext(cacher.WithBackfill(mwCtx), store, opts)
+		_ = store.MSet(setCtx, items)
+		cancel()
+	}
+}
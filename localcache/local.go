@@ -41,6 +41,26 @@ func (r *LocalCache[K, V]) MGet(ctx context.Context, keys []K) (map[K]V, []K, er
 	return ret, miss, nil
 }
 
+// MExists reports which of the keys are cached
+func (r *LocalCache[K, V]) MExists(ctx context.Context, keys []K) (map[K]bool, error) {
+	ret := make(map[K]bool, len(keys))
+	for _, key := range keys {
+		_, ok := r.cache.GetIfPresent(key)
+		ret[key] = ok
+	}
+	return ret, nil
+}
+
+// MTouch restarts the expiration of the cached keys
+func (r *LocalCache[K, V]) MTouch(ctx context.Context, keys []K) error {
+	for _, key := range keys {
+		if _, ok := r.cache.GetIfPresent(key); ok {
+			r.cache.SetExpiresAfter(key, r.ttl)
+		}
+	}
+	return nil
+}
+
 func (r *LocalCache[K, V]) MSet(ctx context.Context, entities map[K]V) error {
 	if len(entities) == 0 {
 		return nil
@@ -79,3 +79,7 @@ func (l *loggerWrapper[K, V]) MDel(ctx context.Context, keys []K) error {
 func (l *loggerWrapper[K, V]) Name() string {
 	return l.next.Name()
 }
+
+func (l *loggerWrapper[K, V]) Unwrap() cacher.Interface[K, V] {
+	return l.next
+}
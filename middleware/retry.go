@@ -0,0 +1,212 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/mbeoliero/tiercache/cacher"
+	"github.com/redis/go-redis/v9"
+)
+
+// retryableReplies are the prefixes of Redis error replies that report a transient server state,
+// e.g. a replica still loading its dataset or a cluster failover in progress
+var retryableReplies = []string{"LOADING", "READONLY", "TRYAGAIN", "CLUSTERDOWN", "MASTERDOWN"}
+
+// RetryOption configures RetryMiddleware
+type RetryOption func(*retryOpts)
+
+type retryOpts struct {
+	attempts  int
+	baseDelay time.Duration
+	maxDelay  time.Duration
+	retryable func(err error) bool
+	budget    *RetryBudget
+}
+
+// WithRetryAttempts sets the maximum number of attempts per call, including the first one.
+func WithRetryAttempts(attempts int) RetryOption {
+	return func(o *retryOpts) {
+		o.attempts = attempts
+	}
+}
+
+// WithRetryBackoff sets the exponential backoff between attempts.
+// The n-th retry waits a random duration in [0, min(maxDelay, baseDelay*2^n)).
+func WithRetryBackoff(baseDelay, maxDelay time.Duration) RetryOption {
+	return func(o *retryOpts) {
+		o.baseDelay = baseDelay
+		o.maxDelay = maxDelay
+	}
+}
+
+// WithRetryable sets the predicate deciding which errors are worth retrying. Defaults to IsRetryable.
+func WithRetryable(retryable func(err error) bool) RetryOption {
+	return func(o *retryOpts) {
+		o.retryable = retryable
+	}
+}
+
+// WithRetryBudget sets the budget limiting how many retries may be issued.
+// A budget can be shared by several middlewares to cap the retry load on one backend.
+// Without it every level wrapped by the middleware gets a budget of its own.
+func WithRetryBudget(budget *RetryBudget) RetryOption {
+	return func(o *retryOpts) {
+		o.budget = budget
+	}
+}
+
+// IsRetryable reports whether err looks like a transient network or timeout error, a Redis connection
+// pool timeout or a Redis reply such as LOADING, READONLY, TRYAGAIN or CLUSTERDOWN.
+// Context cancellation and other errors, e.g. codec errors, are not retryable.
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	for _, prefix := range retryableReplies {
+		if redis.HasErrorPrefix(err, prefix) {
+			return true
+		}
+	}
+	return errors.Is(err, redis.ErrPoolTimeout) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, net.ErrClosed) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE)
+}
+
+// RetryBudget limits retries so they can't pile up load on a struggling backend.
+// It works like a token bucket: every failed attempt takes a token, every success gives back ratio tokens,
+// and retries are only allowed while more than half of maxTokens are left.
+type RetryBudget struct {
+	mu        sync.Mutex
+	tokens    float64
+	maxTokens float64
+	ratio     float64
+}
+
+func NewRetryBudget(maxTokens float64, ratio float64) *RetryBudget {
+	return &RetryBudget{
+		tokens:    maxTokens,
+		maxTokens: maxTokens,
+		ratio:     ratio,
+	}
+}
+
+func (b *RetryBudget) onSuccess() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens = min(b.tokens+b.ratio, b.maxTokens)
+}
+
+// onFailure records a failed attempt and reports whether a retry is allowed
+func (b *RetryBudget) onFailure() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens = max(b.tokens-1, 0)
+	return b.tokens > b.maxTokens/2
+}
+
+type retryWrapper[K comparable, V any] struct {
+	next cacher.Interface[K, V]
+	opts retryOpts
+}
+
+// RetryMiddleware retries store calls that fail with a transient error, using exponential backoff with jitter.
+func RetryMiddleware[K comparable, V any](opts ...RetryOption) cacher.Middleware[K, V] {
+	o := retryOpts{
+		attempts:  3,
+		baseDelay: 10 * time.Millisecond,
+		maxDelay:  time.Second,
+		retryable: IsRetryable,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return func(next cacher.Interface[K, V]) cacher.Interface[K, V] {
+		levelOpts := o
+		if levelOpts.budget == nil {
+			levelOpts.budget = NewRetryBudget(10, 0.1)
+		}
+		return &retryWrapper[K, V]{
+			next: next,
+			opts: levelOpts,
+		}
+	}
+}
+
+func (r *retryWrapper[K, V]) MGet(ctx context.Context, keys []K) (found map[K]V, missing []K, err error) {
+	err = r.do(ctx, func() error {
+		found, missing, err = r.next.MGet(ctx, keys)
+		return err
+	})
+	return found, missing, err
+}
+
+func (r *retryWrapper[K, V]) MSet(ctx context.Context, entities map[K]V) error {
+	return r.do(ctx, func() error {
+		return r.next.MSet(ctx, entities)
+	})
+}
+
+func (r *retryWrapper[K, V]) MDel(ctx context.Context, keys []K) error {
+	return r.do(ctx, func() error {
+		return r.next.MDel(ctx, keys)
+	})
+}
+
+func (r *retryWrapper[K, V]) Name() string {
+	return r.next.Name()
+}
+
+func (r *retryWrapper[K, V]) Unwrap() cacher.Interface[K, V] {
+	return r.next
+}
+
+func (r *retryWrapper[K, V]) do(ctx context.Context, call func() error) error {
+	for attempt := 0; ; attempt++ {
+		err := call()
+		if err == nil {
+			r.opts.budget.onSuccess()
+			return nil
+		}
+		if !r.opts.retryable(err) {
+			return err
+		}
+		allowed := r.opts.budget.onFailure()
+		if !allowed || attempt+1 >= r.opts.attempts {
+			return err
+		}
+
+		timer := time.NewTimer(r.backoff(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+func (r *retryWrapper[K, V]) backoff(attempt int) time.Duration {
+	ceiling := r.opts.maxDelay
+	if shifted := r.opts.baseDelay << attempt; shifted > 0 && shifted < ceiling {
+		ceiling = shifted
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return rand.N(ceiling)
+}
@@ -0,0 +1,114 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+type flakyStore struct {
+	failures int
+	err      error
+	calls    int
+}
+
+func (f *flakyStore) Name() string {
+	return "flaky"
+}
+
+func (f *flakyStore) MGet(ctx context.Context, keys []string) (map[string]string, []string, error) {
+	f.calls++
+	if f.calls <= f.failures {
+		return nil, nil, f.err
+	}
+	return map[string]string{"k": "v"}, []string{}, nil
+}
+
+func (f *flakyStore) MSet(ctx context.Context, entities map[string]string) error {
+	f.calls++
+	if f.calls <= f.failures {
+		return f.err
+	}
+	return nil
+}
+
+func (f *flakyStore) MDel(ctx context.Context, keys []string) error {
+	return nil
+}
+
+func TestRetryMiddleware(t *testing.T) {
+	ctx := context.TODO()
+	mw := RetryMiddleware[string, string](WithRetryAttempts(3), WithRetryBackoff(time.Millisecond, 5*time.Millisecond))
+
+	// transient errors are retried until success
+	store := &flakyStore{failures: 2, err: io.ErrUnexpectedEOF}
+	ret, _, err := mw(store).MGet(ctx, []string{"k"})
+	assert.Nil(t, err)
+	assert.Equal(t, "v", ret["k"])
+	assert.Equal(t, 3, store.calls)
+
+	// attempts are bounded
+	store = &flakyStore{failures: 5, err: io.ErrUnexpectedEOF}
+	assert.ErrorIs(t, mw(store).MSet(ctx, map[string]string{"k": "v"}), io.ErrUnexpectedEOF)
+	assert.Equal(t, 3, store.calls)
+
+	// non-retryable errors are returned immediately
+	store = &flakyStore{failures: 5, err: errors.New("unmarshal failed")}
+	assert.NotNil(t, mw(store).MSet(ctx, map[string]string{"k": "v"}))
+	assert.Equal(t, 1, store.calls)
+
+	// an exhausted budget stops retries
+	budget := NewRetryBudget(2, 0.1)
+	mw = RetryMiddleware[string, string](WithRetryBudget(budget), WithRetryBackoff(time.Millisecond, time.Millisecond))
+	store = &flakyStore{failures: 5, err: io.EOF}
+	assert.NotNil(t, mw(store).MSet(ctx, map[string]string{"k": "v"}))
+	assert.Equal(t, 1, store.calls)
+
+	// without a configured budget every wrapped level gets its own
+	mw = RetryMiddleware[string, string](WithRetryAttempts(3), WithRetryBackoff(time.Millisecond, time.Millisecond))
+	exhausted := mw(&flakyStore{failures: 100, err: io.EOF})
+	for i := 0; i < 5; i++ {
+		assert.NotNil(t, exhausted.MSet(ctx, map[string]string{"k": "v"}))
+	}
+	store = &flakyStore{failures: 2, err: io.EOF}
+	assert.Nil(t, mw(store).MSet(ctx, map[string]string{"k": "v"}))
+	assert.Equal(t, 3, store.calls)
+
+	// a done context stops retries
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	mw = RetryMiddleware[string, string](WithRetryBackoff(time.Second, time.Second))
+	store = &flakyStore{failures: 5, err: io.EOF}
+	assert.NotNil(t, mw(store).MSet(cctx, map[string]string{"k": "v"}))
+	assert.Equal(t, 1, store.calls)
+}
+
+func TestIsRetryable(t *testing.T) {
+	assert.True(t, IsRetryable(io.EOF))
+	assert.True(t, IsRetryable(redis.ErrPoolTimeout))
+	for _, reply := range []string{
+		"LOADING Redis is loading the dataset in memory",
+		"READONLY You can't write against a read only replica.",
+		"TRYAGAIN Multiple keys request during rehashing of slot",
+		"CLUSTERDOWN The cluster is down",
+	} {
+		assert.True(t, IsRetryable(redisReply(reply)), reply)
+	}
+
+	assert.False(t, IsRetryable(nil))
+	assert.False(t, IsRetryable(context.Canceled))
+	assert.False(t, IsRetryable(redis.Nil))
+	assert.False(t, IsRetryable(redisReply("WRONGTYPE Operation against a key holding the wrong kind of value")))
+}
+
+// redisReply is an error reply as go-redis returns it
+type redisReply string
+
+func (e redisReply) Error() string { return string(e) }
+
+func (redisReply) RedisError() {}
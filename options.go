@@ -3,6 +3,7 @@ package tiercache
 import (
 	"context"
 	"sync"
+	"time"
 
 	"github.com/mbeoliero/tiercache/cacher"
 )
@@ -16,6 +17,10 @@ var optionsPool = sync.Pool{
 type cacheOpts struct {
 	shouldSkipLayer       func(ctx context.Context, info cacher.BaseInfo) bool
 	shouldFallbackOnError func(ctx context.Context, info cacher.BaseInfo, err error) bool
+	layerTimeout          func(ctx context.Context, info cacher.BaseInfo) time.Duration
+
+	// skipLoaders restricts a read to the cache levels, it is set by the methods that bring their own loader
+	skipLoaders bool
 }
 
 type OptFunc func(*cacheOpts)
@@ -46,6 +51,55 @@ func WithFallbackOnLayerError(shouldFallback func(ctx context.Context, info cach
 	}
 }
 
+// WithLayerTimeout sets a timeout budget for each cache layer.
+// The timeout function is called before a layer is queried and returns how long that layer may take.
+// A zero or negative duration means the layer only inherits the caller's context deadline.
+// A layer that runs out of time fails with context.DeadlineExceeded, which is handled like any
+// other layer error (see WithFallbackOnLayerError).
+//
+// Example: L1 2ms, Redis 20ms, the rest for the DB
+//
+//	cache.Get(ctx, key, tiercache.WithLayerTimeout(tiercache.LevelTimeouts(2*time.Millisecond, 20*time.Millisecond)))
+func WithLayerTimeout(timeout func(ctx context.Context, info cacher.BaseInfo) time.Duration) OptFunc {
+	return func(opts *cacheOpts) {
+		opts.layerTimeout = timeout
+	}
+}
+
+// LevelTimeouts returns a timeout function for WithLayerTimeout that assigns a fixed timeout per level.
+// timeouts[0] applies to Level 1, timeouts[1] to Level 2 and so on; levels beyond the list have no own timeout.
+func LevelTimeouts(timeouts ...time.Duration) func(ctx context.Context, info cacher.BaseInfo) time.Duration {
+	return func(ctx context.Context, info cacher.BaseInfo) time.Duration {
+		level := cacher.GetRunInfo(ctx).Level()
+		if level < 1 || level > len(timeouts) {
+			return 0
+		}
+		return timeouts[level-1]
+	}
+}
+
+// DeadlineShare returns a timeout function for WithLayerTimeout that gives each level a share
+// of the deadline remaining when the level is queried.
+// shares[0] applies to Level 1, shares[1] to Level 2 and so on; a share of 0.1 allows the level
+// to use 10% of the remaining time. Levels beyond the list, and calls without a deadline, have no own timeout.
+func DeadlineShare(shares ...float64) func(ctx context.Context, info cacher.BaseInfo) time.Duration {
+	return func(ctx context.Context, info cacher.BaseInfo) time.Duration {
+		level := cacher.GetRunInfo(ctx).Level()
+		if level < 1 || level > len(shares) {
+			return 0
+		}
+		deadline, ok := ctx.Deadline()
+		if !ok {
+			return 0
+		}
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return 0
+		}
+		return time.Duration(float64(remaining) * shares[level-1])
+	}
+}
+
 func defaultOpts() *cacheOpts {
 	opt := optionsPool.Get().(*cacheOpts)
 	opt.free()
@@ -55,4 +109,6 @@ func defaultOpts() *cacheOpts {
 func (m *cacheOpts) free() {
 	m.shouldSkipLayer = nil
 	m.shouldFallbackOnError = nil
+	m.layerTimeout = nil
+	m.skipLoaders = false
 }
@@ -0,0 +1,133 @@
+package tiercache
+
+import (
+	"context"
+	"errors"
+
+	"github.com/mbeoliero/tiercache/cacher"
+)
+
+// Entry is a cached value together with the level that holds it.
+type Entry[V any] struct {
+	Value V
+	// Level is the 1-based level the value was found at
+	Level int
+}
+
+// Peek reads the key from the cache levels without back-populating upper levels and without calling loaders.
+func (c *MultiLevelCache[K, V]) Peek(ctx context.Context, key K, opts ...OptFunc) (Entry[V], bool, error) {
+	ret, err := c.MPeek(ctx, []K{key}, opts...)
+	if err != nil {
+		return Entry[V]{}, false, err
+	}
+	entry, ok := ret[key]
+	return entry, ok, nil
+}
+
+// MPeek reads the keys from the cache levels without back-populating upper levels and without calling loaders.
+// Each found key is reported with the topmost level holding it.
+func (c *MultiLevelCache[K, V]) MPeek(ctx context.Context, keys []K, opts ...OptFunc) (map[K]Entry[V], error) {
+	ret := make(map[K]Entry[V], len(keys))
+	err := c.walkCacheLevels(ctx, keys, opts, func(ctx context.Context, level int, store cacher.Interface[K, V], keys []K) ([]K, error) {
+		found, _, err := store.MGet(ctx, keys)
+		for k, v := range found {
+			ret[k] = Entry[V]{Value: v, Level: level}
+		}
+		return remaining(keys, found), err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// Exists reports whether a cache level holds the key, and which one.
+// Like Peek it doesn't back-populate and doesn't call loaders.
+func (c *MultiLevelCache[K, V]) Exists(ctx context.Context, key K, opts ...OptFunc) (int, bool, error) {
+	ret, err := c.MExists(ctx, []K{key}, opts...)
+	if err != nil {
+		return 0, false, err
+	}
+	level, ok := ret[key]
+	return level, ok, nil
+}
+
+// MExists reports the topmost level holding each of the keys; keys no cache level holds are left out.
+// Stores implementing cacher.Exister are asked without reading the values, other stores are read with MGet.
+func (c *MultiLevelCache[K, V]) MExists(ctx context.Context, keys []K, opts ...OptFunc) (map[K]int, error) {
+	ret := make(map[K]int, len(keys))
+	err := c.walkCacheLevels(ctx, keys, opts, func(ctx context.Context, level int, store cacher.Interface[K, V], keys []K) ([]K, error) {
+		if exister, ok := cacher.As[cacher.Exister[K]](store); ok {
+			exists, err := exister.MExists(ctx, keys)
+			if err != nil {
+				return keys, err
+			}
+			left := make([]K, 0, len(keys))
+			for _, k := range keys {
+				if exists[k] {
+					ret[k] = level
+				} else {
+					left = append(left, k)
+				}
+			}
+			return left, nil
+		}
+
+		found, _, err := store.MGet(ctx, keys)
+		for k := range found {
+			ret[k] = level
+		}
+		return remaining(keys, found), err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// walkCacheLevels calls visit for each cache level from top to bottom with the keys not found so far,
+// skipping loader levels and the levels excluded by the options. visit returns the keys still to look for.
+// Level errors are handled like in MGet: by default the level is skipped, otherwise the error is returned.
+func (c *MultiLevelCache[K, V]) walkCacheLevels(ctx context.Context, keys []K, opts []OptFunc,
+	visit func(ctx context.Context, level int, store cacher.Interface[K, V], keys []K) ([]K, error)) error {
+	o := defaultOpts()
+	for _, opt := range opts {
+		opt(o)
+	}
+	o.skipLoaders = true
+	defer optionsPool.Put(o)
+
+	for i, store := range c.stores {
+		if len(keys) == 0 {
+			return nil
+		}
+		mwCtx := cacher.NewContext(ctx, cacher.NewRunInfo(i+1))
+		if c.skipLayer(mwCtx, store, o) {
+			continue
+		}
+
+		layerCtx, cancel := c.layerContext(mwCtx, store, o)
+		left, err := visit(layerCtx, i+1, store, keys)
+		cancel()
+
+		var partialErr *cacher.PartialError[K]
+		if err != nil && !errors.As(err, &partialErr) && !c.shouldFallback(mwCtx, store, err, o) {
+			return err
+		}
+		if err == nil || partialErr != nil {
+			keys = left
+		}
+	}
+	return nil
+}
+
+// remaining returns the keys that are not in found
+func remaining[K comparable, V any](keys []K, found map[K]V) []K {
+	left := make([]K, 0, len(keys))
+	for _, k := range keys {
+		if _, ok := found[k]; !ok {
+			left = append(left, k)
+		}
+	}
+	return left
+}
@@ -0,0 +1,38 @@
+package preset
+
+import (
+	"github.com/mbeoliero/tiercache/cacher"
+	"github.com/mbeoliero/tiercache/datasource"
+)
+
+// Option configures the caches built by the preset ...WithOptions constructors
+type Option[K comparable, V any] func(*options[K, V])
+
+type options[K comparable, V any] struct {
+	mws         []cacher.Middleware[K, V]
+	fetcherOpts []datasource.AdapterOption
+}
+
+// WithMiddlewares adds middlewares that are applied to every level of the cache
+func WithMiddlewares[K comparable, V any](mws ...cacher.Middleware[K, V]) Option[K, V] {
+	return func(o *options[K, V]) {
+		o.mws = append(o.mws, mws...)
+	}
+}
+
+// WithFetcherOptions configures how the fetcher is called on a miss, e.g. how many keys are fetched in parallel.
+//
+//	preset.WithFetcherOptions[int, User](datasource.WithConcurrency(8))
+func WithFetcherOptions[K comparable, V any](opts ...datasource.AdapterOption) Option[K, V] {
+	return func(o *options[K, V]) {
+		o.fetcherOpts = append(o.fetcherOpts, opts...)
+	}
+}
+
+func newOptions[K comparable, V any](opts []Option[K, V]) *options[K, V] {
+	o := &options[K, V]{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
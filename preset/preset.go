@@ -20,6 +20,18 @@ func NewRedisCache[K comparable, V any](
 	fetcher datasource.Fetcher[K, V],
 	mws ...cacher.Middleware[K, V],
 ) *tiercache.MultiLevelCache[K, V] {
+	return NewRedisCacheWithOptions(client, prefix, ttl, fetcher, WithMiddlewares(mws...))
+}
+
+// NewRedisCacheWithOptions is NewRedisCache configured by options, e.g. to tune how the fetcher is called.
+func NewRedisCacheWithOptions[K comparable, V any](
+	client redis.UniversalClient,
+	prefix string,
+	ttl time.Duration,
+	fetcher datasource.Fetcher[K, V],
+	opts ...Option[K, V],
+) *tiercache.MultiLevelCache[K, V] {
+	o := newOptions(opts)
 
 	// L1: Redis Cache
 	redisStore := rediscache.NewRedisCache[K, V](client, ttl).
@@ -28,13 +40,13 @@ func NewRedisCache[K comparable, V any](
 
 	// L2: Data Source (DB)
 	// Convert the single-key fetcher to a batch fetcher automatically
-	ds := datasource.NewDataSourceWithFetcher[K, V](fetcher)
+	ds := datasource.NewDataSourceWithFetcher[K, V](fetcher, o.fetcherOpts...)
 
 	c := tiercache.NewMultiLevelCache[K, V](
 		redisStore,
 		ds,
 	)
-	for _, m := range mws {
+	for _, m := range o.mws {
 		c = c.Use(m)
 	}
 	return c.Build()
@@ -51,6 +63,19 @@ func NewLocalAndRedisCache[K comparable, V any](
 	fetcher datasource.Fetcher[K, V],
 	mws ...cacher.Middleware[K, V],
 ) *tiercache.MultiLevelCache[K, V] {
+	return NewLocalAndRedisCacheWithOptions(client, prefix, redisTTL, localTTL, fetcher, WithMiddlewares(mws...))
+}
+
+// NewLocalAndRedisCacheWithOptions is NewLocalAndRedisCache configured by options, e.g. to tune how the fetcher is called.
+func NewLocalAndRedisCacheWithOptions[K comparable, V any](
+	client redis.UniversalClient,
+	prefix string,
+	redisTTL time.Duration,
+	localTTL time.Duration,
+	fetcher datasource.Fetcher[K, V],
+	opts ...Option[K, V],
+) *tiercache.MultiLevelCache[K, V] {
+	o := newOptions(opts)
 
 	// L1: Local Memory Cache
 	localStore := localcache.NewLocalCache[K, V](localTTL)
@@ -61,14 +86,14 @@ func NewLocalAndRedisCache[K comparable, V any](
 		ToStore()
 
 	// L3: Data Source (DB)
-	ds := datasource.NewDataSourceWithFetcher[K, V](fetcher)
+	ds := datasource.NewDataSourceWithFetcher[K, V](fetcher, o.fetcherOpts...)
 
 	c := tiercache.NewMultiLevelCache[K, V](
 		localStore,
 		redisStore,
 		ds,
 	)
-	for _, m := range mws {
+	for _, m := range o.mws {
 		c = c.Use(m)
 	}
 	return c.Build()
@@ -2,10 +2,12 @@ package preset
 
 import (
 	"context"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/alicebob/miniredis/v2"
+	"github.com/mbeoliero/tiercache/datasource"
 	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/assert"
 )
@@ -66,3 +68,40 @@ func TestNewLocalAndRedisCache(t *testing.T) {
 	assert.Equal(t, 1, u.ID)
 	assert.Equal(t, "user", u.Name)
 }
+
+func TestFetcherOptions(t *testing.T) {
+	rdb := setupRedis(t)
+	ctx := context.Background()
+
+	var running, peak atomic.Int32
+	fetcher := func(ctx context.Context, id int) (User, error) {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		return User{ID: id, Name: "user"}, nil
+	}
+
+	cache := NewRedisCacheWithOptions[int, User](
+		rdb,
+		"user:",
+		time.Minute,
+		fetcher,
+		WithFetcherOptions[int, User](datasource.WithConcurrency(4)),
+	)
+
+	ids := make([]int, 20)
+	for i := range ids {
+		ids[i] = i
+	}
+	users, err := cache.MGet(ctx, ids)
+	assert.NoError(t, err)
+	assert.Len(t, users, 20)
+	assert.LessOrEqual(t, peak.Load(), int32(4))
+	assert.Greater(t, peak.Load(), int32(1))
+}
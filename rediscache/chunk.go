@@ -0,0 +1,251 @@
+package rediscache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// chunkMagic starts the manifest stored under the key of a chunked value, followed by
+// "<gen>:<chunks>:<size>:<crc>". A codec could in principle produce a value starting with it, e.g. CompressCodec
+// writes uncompressed values after a 0x00 byte, so a value is only taken for a manifest if all of it parses
+// as one; the version allows changing the manifest format later.
+const chunkMagic = "\x00tiercache-chunks:v1:"
+
+// chunkSetScript stores a chunked value: KEYS[1] gets the manifest and KEYS[2..] the chunks.
+// ARGV[1] is the TTL in milliseconds, ARGV[2] the manifest magic, ARGV[3] the manifest and ARGV[4..] the chunks.
+// Like swapSetScript it returns the replaced manifest, so the client can delete the old chunks.
+var chunkSetScript = redis.NewScript(`
+local ttl = tonumber(ARGV[1])
+local magic = ARGV[2]
+local cur = redis.call('GET', KEYS[1])
+for i = 1, #KEYS do
+	local val = ARGV[i + 2]
+	if ttl > 0 then
+		redis.call('SET', KEYS[i], val, 'PX', ttl)
+	else
+		redis.call('SET', KEYS[i], val)
+	end
+end
+if cur and string.sub(cur, 1, #magic) == magic then return {1, cur} end
+return {}
+`)
+
+// swapSetScript works like setScript, and returns the index and value of every replaced manifest
+var swapSetScript = redis.NewScript(`
+local ttl = tonumber(ARGV[1])
+local magic = ARGV[2]
+local old = {}
+for i, key in ipairs(KEYS) do
+	local cur = redis.call('GET', key)
+	if cur and string.sub(cur, 1, #magic) == magic then
+		old[#old + 1] = i
+		old[#old + 1] = cur
+	end
+	if ttl > 0 then
+		redis.call('SET', key, ARGV[i + 2], 'PX', ttl)
+	else
+		redis.call('SET', key, ARGV[i + 2])
+	end
+end
+return old
+`)
+
+// swapDelScript deletes the keys, and returns the index and value of every deleted manifest
+var swapDelScript = redis.NewScript(`
+local magic = ARGV[1]
+local old = {}
+for i, key in ipairs(KEYS) do
+	local cur = redis.call('GET', key)
+	if cur and string.sub(cur, 1, #magic) == magic then
+		old[#old + 1] = i
+		old[#old + 1] = cur
+	end
+	redis.call('DEL', key)
+end
+return old
+`)
+
+// errChunks is reported when the chunks of a value are missing or don't match their manifest
+var errChunks = errors.New("redis-cache: incomplete chunked value")
+
+// SetChunking splits encoded values larger than threshold bytes into chunks of chunkSize bytes, stored
+// under keys derived from the key of the value. The key itself holds a manifest with the number of chunks,
+// the size and a CRC-32 of the value. Chunks are written together with the manifest in one script and
+// every write uses new chunk keys, so readers either see the complete old or the complete new value;
+// a value whose chunks are missing or don't match the manifest is read as a miss.
+// Chunks of overwritten and deleted values are removed. They share the hash tag of their key, so they
+// live in the same Redis Cluster slot.
+//
+// Chunking is not used with versioning, in hash mode and for values written by Compute. Sliding expiration
+// and MTouch extend the TTL of the chunks together with the manifest.
+func (r *RedisCache[K, V]) SetChunking(threshold, chunkSize int) *RedisCache[K, V] {
+	if chunkSize <= 0 {
+		chunkSize = threshold
+	}
+	r.opt.ChunkThreshold = threshold
+	r.opt.ChunkSize = chunkSize
+	return r
+}
+
+func (r *RedisCache[K, V]) chunkingEnabled() bool {
+	return r.opt.ChunkThreshold > 0 && !r.opt.Versioned && r.opt.HashBucket == nil
+}
+
+// manifest describes a chunked value
+type manifest struct {
+	gen  string
+	n    int
+	size int
+	crc  uint32
+}
+
+func newManifest(data []byte, chunkSize int) manifest {
+	return manifest{
+		gen:  strconv.FormatUint(rand.Uint64(), 36),
+		n:    (len(data) + chunkSize - 1) / chunkSize,
+		size: len(data),
+		crc:  crc32.ChecksumIEEE(data),
+	}
+}
+
+func (m manifest) String() string {
+	return fmt.Sprintf("%s%s:%d:%d:%d", chunkMagic, m.gen, m.n, m.size, m.crc)
+}
+
+func parseManifest(value string) (manifest, bool) {
+	rest, ok := strings.CutPrefix(value, chunkMagic)
+	if !ok {
+		return manifest{}, false
+	}
+	parts := strings.Split(rest, ":")
+	if len(parts) != 4 {
+		return manifest{}, false
+	}
+	_, err0 := strconv.ParseUint(parts[0], 36, 64)
+	n, err1 := strconv.Atoi(parts[1])
+	size, err2 := strconv.Atoi(parts[2])
+	crc, err3 := strconv.ParseUint(parts[3], 10, 32)
+	if err := errors.Join(err0, err1, err2, err3); err != nil || n <= 0 || size < n {
+		return manifest{}, false
+	}
+	return manifest{gen: parts[0], n: n, size: size, crc: uint32(crc)}, true
+}
+
+// chunkKeys returns the keys of the chunks. They reuse the hash tag of redisKey, or use redisKey
+// as their hash tag, so they hash to the same cluster slot as redisKey.
+func (m manifest) chunkKeys(redisKey string) []string {
+	base := "{" + redisKey + "}:chunk:"
+	if start := strings.IndexByte(redisKey, '{'); start >= 0 && strings.IndexByte(redisKey[start+1:], '}') > 0 {
+		base = redisKey + ":chunk:"
+	}
+	ret := make([]string, m.n)
+	for i := range ret {
+		ret[i] = base + m.gen + ":" + strconv.Itoa(i)
+	}
+	return ret
+}
+
+// assemble joins the chunks and checks them against the manifest
+func (m manifest) assemble(chunks []any) ([]byte, error) {
+	data := make([]byte, 0, m.size)
+	for _, chunk := range chunks {
+		s, ok := chunk.(string)
+		if !ok {
+			return nil, errChunks
+		}
+		data = append(data, s...)
+	}
+	if len(data) != m.size || crc32.ChecksumIEEE(data) != m.crc {
+		return nil, errChunks
+	}
+	return data, nil
+}
+
+// queueChunkedSet adds the script call writing a chunked value to the pipeline
+func (r *RedisCache[K, V]) queueChunkedSet(ctx context.Context, p redis.Pipeliner, redisKey string, data []byte) *redis.Cmd {
+	m := newManifest(data, r.opt.ChunkSize)
+	keys := append([]string{redisKey}, m.chunkKeys(redisKey)...)
+	args := make([]any, 0, m.n+3)
+	args = append(args, r.ttl.Milliseconds(), chunkMagic, m.String())
+	for i := 0; i < len(data); i += r.opt.ChunkSize {
+		args = append(args, data[i:min(i+r.opt.ChunkSize, len(data))])
+	}
+	return chunkSetScript.Eval(ctx, p, keys, args...)
+}
+
+// chunkRead is a chunked value found by MGet
+type chunkRead struct {
+	idx      int
+	redisKey string
+	m        manifest
+}
+
+// readChunks reads and assembles the chunked values with one MGET per value, all in one pipeline.
+// errs holds the values whose read failed, and errChunks for the values with missing or corrupt chunks.
+// Each manifest is read again after its chunks: a value overwritten or deleted while it was read can
+// have lost its chunks without being corrupt, so it is left out of both maps and read as a miss.
+func (r *RedisCache[K, V]) readChunks(ctx context.Context, cli redis.UniversalClient, reads []chunkRead) (map[int][]byte, map[int]error) {
+	p := cli.Pipeline()
+	cmds := make([]*redis.SliceCmd, 0, len(reads))
+	manifests := make([]*redis.StringCmd, 0, len(reads))
+	for _, read := range reads {
+		cmds = append(cmds, p.MGet(ctx, read.m.chunkKeys(read.redisKey)...))
+		manifests = append(manifests, p.Get(ctx, read.redisKey))
+	}
+	_ = execPipeline(ctx, p)
+
+	data := make(map[int][]byte, len(reads))
+	errs := make(map[int]error)
+	for i, cmd := range cmds {
+		if err := cmd.Err(); err != nil {
+			errs[reads[i].idx] = err
+			continue
+		}
+		value, err := reads[i].m.assemble(cmd.Val())
+		if err != nil {
+			if cur, curErr := manifests[i].Result(); (curErr == nil || errors.Is(curErr, redis.Nil)) && cur != reads[i].m.String() {
+				continue
+			}
+			errs[reads[i].idx] = err
+			continue
+		}
+		data[reads[i].idx] = value
+	}
+	return data, errs
+}
+
+// dropReplacedChunks deletes the chunks of the manifests returned by chunkSetScript, swapSetScript
+// and swapDelScript. Failures are only logged, the chunks expire with their TTL.
+func (r *RedisCache[K, V]) dropReplacedChunks(ctx context.Context, redisKeys []string, batches [][]int, cmds []*redis.Cmd) {
+	p := r.cli.Pipeline()
+	var n int
+	for i, cmd := range cmds {
+		old, err := cmd.Slice()
+		if err != nil {
+			continue
+		}
+		for j := 0; j+1 < len(old); j += 2 {
+			pos, _ := old[j].(int64)
+			value, _ := old[j+1].(string)
+			m, ok := parseManifest(value)
+			if !ok || pos < 1 || int(pos) > len(batches[i]) {
+				continue
+			}
+			p.Del(ctx, m.chunkKeys(redisKeys[batches[i][pos-1]])...)
+			n++
+		}
+	}
+	if n == 0 {
+		return
+	}
+	if err := execPipeline(ctx, p); err != nil && r.opt.Logger != nil {
+		r.opt.Logger.CtxError(ctx, "[redis-cache] delete replaced chunks failed. err=%v", err)
+	}
+}
@@ -0,0 +1,140 @@
+package rediscache
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"slices"
+	"time"
+
+	"github.com/mbeoliero/tiercache/cacher"
+	"github.com/mbeoliero/tiercache/codec"
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrComputeConflict is returned by Compute when the key kept changing concurrently for all retries
+var ErrComputeConflict = errors.New("redis-cache: compute retries exhausted due to concurrent writes")
+
+func (r *RedisCache[K, V]) SetMaxComputeRetries(retries int) *RedisCache[K, V] {
+	r.opt.MaxComputeRetries = retries
+	return r
+}
+
+// Compute runs an atomic read-modify-write on the key using optimistic concurrency (WATCH/MULTI/EXEC).
+// If the key is modified between the read and the write, fn is called again with the new value.
+// In hash mode the whole hash is watched, so writes to other fields of the same hash also cause a retry.
+func (r *RedisCache[K, V]) Compute(ctx context.Context, key K, fn cacher.ComputeFunc[V]) (V, cacher.Action, error) {
+	defer r.markWritten(slices.Values([]K{key}))
+	redisKey, computeTx := r.getRedisKey(key), r.computeTx
+	if r.opt.HashBucket != nil {
+		redisKey, computeTx = r.prefix+r.opt.HashBucket(key), r.hashComputeTx
+	}
+	for attempt := 0; attempt <= r.opt.MaxComputeRetries; attempt++ {
+		var (
+			ret    V
+			action cacher.Action
+		)
+		err := r.cli.Watch(ctx, func(tx *redis.Tx) error {
+			var err error
+			ret, action, err = computeTx(ctx, tx, key, redisKey, fn)
+			return err
+		}, redisKey)
+		if err == nil && action == cacher.ActionSet && r.opt.HashBucket != nil {
+			// field TTLs are set outside the transaction, as a server without HPEXPIRE would abort it
+			err = r.hashMTouch(ctx, []K{key})
+		}
+
+		if errors.Is(err, redis.TxFailedErr) {
+			if r.opt.Logger != nil {
+				r.opt.Logger.CtxDebug(ctx, "[redis-cache] compute conflict, retrying. key=%v,attempt=%d", redisKey, attempt)
+			}
+			// back off a little so contending writers don't keep invalidating each other
+			select {
+			case <-ctx.Done():
+				var zero V
+				return zero, cacher.ActionKeep, ctx.Err()
+			case <-time.After(rand.N(time.Duration(attempt+1) * time.Millisecond)):
+			}
+			continue
+		}
+		if err != nil {
+			if r.opt.Logger != nil {
+				r.opt.Logger.CtxError(ctx, "[redis-cache] compute failed. key=%v,err=%v", redisKey, err)
+			}
+			var zero V
+			return zero, cacher.ActionKeep, err
+		}
+		return ret, action, nil
+	}
+
+	var zero V
+	return zero, cacher.ActionKeep, ErrComputeConflict
+}
+
+// computeTx runs fn on the watched key and queues its result in a transaction
+func (r *RedisCache[K, V]) computeTx(ctx context.Context, tx *redis.Tx, key K, redisKey string, fn cacher.ComputeFunc[V]) (V, cacher.Action, error) {
+	old, ver, oldChunks, found, err := r.getTx(ctx, tx, key, redisKey)
+	if err != nil {
+		return old, cacher.ActionKeep, err
+	}
+
+	ret, action := fn(old, found)
+	switch action {
+	case cacher.ActionSet:
+		data, err := r.encodeValue(key, ret, ver+1)
+		if err != nil {
+			return ret, action, err
+		}
+		_, err = tx.TxPipelined(ctx, func(p redis.Pipeliner) error {
+			p.Set(ctx, redisKey, data, r.ttl)
+			if len(oldChunks) > 0 {
+				p.Del(ctx, oldChunks...)
+			}
+			return nil
+		})
+		return ret, action, err
+	case cacher.ActionDelete:
+		var zero V
+		_, err = tx.TxPipelined(ctx, func(p redis.Pipeliner) error {
+			p.Del(ctx, append(oldChunks, redisKey)...)
+			return nil
+		})
+		return zero, action, err
+	default:
+		return old, cacher.ActionKeep, nil
+	}
+}
+
+// getTx reads and decodes the watched key. For a chunked value it also returns the chunk keys,
+// which are dropped when the value is replaced.
+func (r *RedisCache[K, V]) getTx(ctx context.Context, tx *redis.Tx, key K, redisKey string) (V, uint64, []string, bool, error) {
+	var zero V
+	data, err := tx.Get(ctx, redisKey).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return zero, 0, nil, false, nil
+	}
+	if err != nil {
+		return zero, 0, nil, false, err
+	}
+	var chunkKeys []string
+	if m, ok := parseManifest(string(data)); ok {
+		chunkKeys = m.chunkKeys(redisKey)
+		chunks, err := tx.MGet(ctx, chunkKeys...).Result()
+		if err != nil {
+			return zero, 0, nil, false, err
+		}
+		if data, err = m.assemble(chunks); err != nil {
+			// an incomplete value is treated as missing, and replaced
+			return zero, 0, chunkKeys, false, nil
+		}
+	}
+	entity, ver, err := r.decodeValue(key, data)
+	if errors.Is(err, codec.ErrDiscard) {
+		// a value the codec gave up on is treated as missing, and replaced
+		return zero, 0, chunkKeys, false, nil
+	}
+	if err != nil {
+		return zero, 0, nil, false, err
+	}
+	return entity, ver, chunkKeys, true, nil
+}
@@ -0,0 +1,86 @@
+package rediscache
+
+import (
+	"context"
+	"errors"
+
+	"github.com/mbeoliero/tiercache/codec"
+	"github.com/redis/go-redis/v9"
+)
+
+// discardScript deletes KEYS[1], or the field ARGV[2] of the hash KEYS[1], only if it still holds ARGV[1],
+// so a value written since it was read is kept. The other keys, the chunks of the value, are deleted with it.
+var discardScript = redis.NewScript(`
+if ARGV[2] ~= '' then
+	if redis.call('HGET', KEYS[1], ARGV[2]) == ARGV[1] then
+		return redis.call('HDEL', KEYS[1], ARGV[2])
+	end
+	return 0
+end
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', unpack(KEYS))
+end
+return 0
+`)
+
+// SetOnCorrupt sets a hook called for every value MGet fails to decode, e.g. to export a metric or alert.
+// In hash mode redisKey is the hash and the field separated by a space. Such values are read as misses.
+func (r *RedisCache[K, V]) SetOnCorrupt(fn func(ctx context.Context, redisKey string, err error)) *RedisCache[K, V] {
+	r.opt.OnCorrupt = fn
+	return r
+}
+
+// SetDropUndecodable makes MGet delete every value it fails to decode, so it is not fetched and failing again
+// until it expires. Values the codec marks with codec.ErrDiscard, such as failed checksums, and chunked values
+// with missing or corrupt chunks are always deleted.
+// Values of unknown schema versions or encryption keys are kept unless their codec says otherwise, since they
+// may have been written by a newer deployment.
+func (r *RedisCache[K, V]) SetDropUndecodable(drop bool) *RedisCache[K, V] {
+	r.opt.DropUndecodable = drop
+	return r
+}
+
+// CorruptEntries returns the number of values MGet failed to decode
+func (r *RedisCache[K, V]) CorruptEntries() uint64 {
+	return r.corruptEntries.Load()
+}
+
+// corrupt records a value that failed to decode and reports whether it is to be deleted
+func (r *RedisCache[K, V]) corrupt(ctx context.Context, redisKey string, err error) bool {
+	r.corruptEntries.Add(1)
+	if r.opt.Logger != nil {
+		r.opt.Logger.CtxError(ctx, "[redis-cache] unmarshall failed. key=%v,err=%v", redisKey, err)
+	}
+	if r.opt.OnCorrupt != nil {
+		r.opt.OnCorrupt(ctx, redisKey, err)
+	}
+	if errors.Is(err, codec.ErrDiscard) || errors.Is(err, errChunks) {
+		return true
+	}
+	return r.opt.DropUndecodable && !errors.Is(err, codec.ErrUnknownSchema) && !errors.Is(err, codec.ErrUnknownKeyID)
+}
+
+// discarded is a stored value to delete because it failed to decode
+type discarded struct {
+	redisKey string
+	// field is the hash field in hash mode
+	field string
+	// value is the stored value as read, for chunked values the manifest
+	value string
+	// chunks are the chunk keys of a chunked value
+	chunks []string
+}
+
+// discard deletes the values through the primary. Failures are only logged, the values are read as misses anyway.
+func (r *RedisCache[K, V]) discard(ctx context.Context, items []discarded) {
+	if len(items) == 0 {
+		return
+	}
+	p := r.cli.Pipeline()
+	for _, item := range items {
+		discardScript.Eval(ctx, p, append([]string{item.redisKey}, item.chunks...), item.value, item.field)
+	}
+	if err := execPipeline(ctx, p); err != nil && r.opt.Logger != nil {
+		r.opt.Logger.CtxError(ctx, "[redis-cache] discard undecodable values failed. err=%v", err)
+	}
+}
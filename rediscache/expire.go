@@ -0,0 +1,91 @@
+package rediscache
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// slideScript resets the TTL of the keys whose remaining TTL is below the threshold, or of all existing keys
+// if the threshold is 0. ARGV[1] is the TTL and ARGV[2] the threshold, both in milliseconds. With a threshold,
+// keys without a TTL are left alone. ARGV[3] is the manifest magic when chunking is enabled: the chunks of
+// a chunked value get the same TTL as its manifest. The chunk keys are derived like manifest.chunkKeys does.
+var slideScript = redis.NewScript(`
+local ttl = tonumber(ARGV[1])
+local threshold = tonumber(ARGV[2])
+local magic = ARGV[3]
+local n = 0
+for _, key in ipairs(KEYS) do
+	local left = redis.call('PTTL', key)
+	if (threshold <= 0 and left ~= -2) or (left >= 0 and left < threshold) then
+		redis.call('PEXPIRE', key, ttl)
+		n = n + 1
+		local cur = magic ~= '' and redis.call('GET', key)
+		if cur and string.sub(cur, 1, #magic) == magic then
+			local gen, count = string.match(string.sub(cur, #magic + 1), '^(%w+):(%d+):%d+:%d+$')
+			local base = '{' .. key .. '}:chunk:'
+			local open = string.find(key, '{', 1, true)
+			if open then
+				local close = string.find(key, '}', open + 1, true)
+				if close and close > open + 1 then base = key .. ':chunk:' end
+			end
+			for i = 0, (tonumber(count) or 0) - 1 do
+				redis.call('PEXPIRE', base .. gen .. ':' .. i, ttl)
+			end
+		end
+	end
+end
+return n
+`)
+
+// SetSlidingExpiration makes MGet extend the TTL of the keys it reads, like the access-based expiry of LocalCache.
+// To keep reads cheap a key is only touched when its remaining TTL is below threshold; the TTL is then reset
+// to the cache TTL. The check runs in the same pipeline as the MGET. Reads in hash mode don't extend TTLs,
+// use MTouch there instead.
+func (r *RedisCache[K, V]) SetSlidingExpiration(threshold time.Duration) *RedisCache[K, V] {
+	r.opt.SlideThreshold = threshold
+	return r
+}
+
+// MTouch resets the TTL of the keys to the cache TTL. Missing keys are ignored.
+func (r *RedisCache[K, V]) MTouch(ctx context.Context, keys []K) error {
+	if len(keys) == 0 || r.ttl <= 0 {
+		return nil
+	}
+	if r.opt.HashBucket != nil {
+		return r.hashMTouch(ctx, keys)
+	}
+
+	p := r.cli.Pipeline()
+	if r.chunkingEnabled() {
+		// chunked values need their chunks extended too, which takes a script
+		redisKeys := r.getRedisKeys(keys)
+		for _, b := range r.batches(redisKeys, r.opt.MSetChunkSize) {
+			slideScript.Eval(ctx, p, pick(redisKeys, b), r.ttl.Milliseconds(), 0, chunkMagic)
+		}
+	} else {
+		for _, key := range keys {
+			p.PExpire(ctx, r.getRedisKey(key), r.ttl)
+		}
+	}
+	if err := execPipeline(ctx, p); err != nil {
+		if r.opt.Logger != nil {
+			r.opt.Logger.CtxError(ctx, "[redis-cache] MTouch exec pipeline failed. err=%v", err)
+		}
+		return err
+	}
+	return nil
+}
+
+// queueSlide adds the sliding expiration check of a batch of keys to the pipeline
+func (r *RedisCache[K, V]) queueSlide(ctx context.Context, p redis.Pipeliner, redisKeys []string) *redis.Cmd {
+	if r.opt.SlideThreshold <= 0 || r.ttl <= 0 {
+		return nil
+	}
+	magic := ""
+	if r.chunkingEnabled() {
+		magic = chunkMagic
+	}
+	return slideScript.Eval(ctx, p, redisKeys, r.ttl.Milliseconds(), r.opt.SlideThreshold.Milliseconds(), magic)
+}
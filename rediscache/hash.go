@@ -0,0 +1,287 @@
+package rediscache
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+
+	"github.com/mbeoliero/tiercache/cacher"
+	"github.com/mbeoliero/tiercache/codec"
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrHashMode is returned by the methods that are not available in hash mode
+var ErrHashMode = errors.New("redis-cache: not supported in hash mode")
+
+// states of the HEXPIRE support detection
+const (
+	hexpireUnknown int32 = iota
+	hexpireSupported
+	hexpireUnsupported
+)
+
+// SetHashMode stores entries as fields of Redis hashes instead of one key per entry, which saves memory
+// for many small entries such as per-user settings. The hash of a key is prefix + bucket(key) and the field is the key.
+// Field TTLs use HPEXPIRE where the server supports it (Redis 7.4+); otherwise the TTL applies to the whole hash
+// and is refreshed on every write. Versioning is not available in hash mode.
+func (r *RedisCache[K, V]) SetHashMode(bucket func(K) string) *RedisCache[K, V] {
+	r.opt.HashBucket = bucket
+	return r
+}
+
+// hashGroup is the fields of one hash touched by a call, with the indexes of their keys
+type hashGroup struct {
+	hash   string
+	fields []string
+	idx    []int
+}
+
+func (r *RedisCache[K, V]) hashGroups(keys []K, size int) []hashGroup {
+	byHash := make(map[string]int)
+	var groups []hashGroup
+	for i, k := range keys {
+		hash := r.prefix + r.opt.HashBucket(k)
+		g, ok := byHash[hash]
+		if !ok || (size > 0 && len(groups[g].fields) >= size) {
+			g = len(groups)
+			byHash[hash] = g
+			groups = append(groups, hashGroup{hash: hash})
+		}
+		groups[g].fields = append(groups[g].fields, r.opt.KeyEncoder.EncodeKey(k))
+		groups[g].idx = append(groups[g].idx, i)
+	}
+	return groups
+}
+
+func (r *RedisCache[K, V]) hashMGet(ctx context.Context, cli redis.UniversalClient, keys []K) (map[K]V, []K, error) {
+	ret := make(map[K]V, len(keys))
+	groups := r.hashGroups(keys, r.opt.MGetChunkSize)
+	p := cli.Pipeline()
+	cmds := make([]*redis.SliceCmd, 0, len(groups))
+	batches := make([][]int, 0, len(groups))
+	for _, g := range groups {
+		cmds = append(cmds, p.HMGet(ctx, g.hash, g.fields...))
+		batches = append(batches, g.idx)
+	}
+	_ = execPipeline(ctx, p)
+	if err := batchErrors(keys, batches, cmds); err != nil {
+		var partialErr *cacher.PartialError[K]
+		if !errors.As(err, &partialErr) {
+			if r.opt.Logger != nil {
+				r.opt.Logger.CtxError(ctx, "[redis-cache] HMGET exec pipeline failed. err=%v", err)
+			}
+			return nil, nil, err
+		}
+	}
+
+	var (
+		failed   map[K]error
+		discards []discarded
+	)
+	for i, cmd := range cmds {
+		if err := cmd.Err(); err != nil {
+			if failed == nil {
+				failed = make(map[K]error)
+			}
+			for _, idx := range batches[i] {
+				failed[keys[idx]] = err
+			}
+			continue
+		}
+		for j, result := range cmd.Val() {
+			value, ok := result.(string)
+			if !ok {
+				continue
+			}
+			entity, _, err := r.decodeValue(keys[batches[i][j]], []byte(value))
+			if err != nil {
+				if r.corrupt(ctx, groups[i].hash+" "+groups[i].fields[j], err) {
+					discards = append(discards, discarded{redisKey: groups[i].hash, field: groups[i].fields[j], value: value})
+				}
+				continue
+			}
+			ret[keys[batches[i][j]]] = entity
+		}
+	}
+	r.discard(ctx, discards)
+
+	miss := make([]K, 0)
+	for _, key := range keys {
+		if _, ok := ret[key]; ok {
+			continue
+		}
+		if _, ok := failed[key]; !ok {
+			miss = append(miss, key)
+		}
+	}
+	if len(failed) > 0 {
+		return ret, miss, cacher.NewPartialError(failed)
+	}
+	return ret, miss, nil
+}
+
+func (r *RedisCache[K, V]) hashMSet(ctx context.Context, entities map[K]V) error {
+	keys := make([]K, 0, len(entities))
+	for k := range entities {
+		keys = append(keys, k)
+	}
+	groups := r.hashGroups(keys, r.opt.MSetChunkSize)
+
+	p := r.cli.Pipeline()
+	setCmds := make([]*redis.IntCmd, 0, len(groups))
+	batches := make([][]int, 0, len(groups))
+	for _, g := range groups {
+		args := make([]any, 0, len(g.fields)*2)
+		for j, field := range g.fields {
+			data, err := r.marshal(keys[g.idx[j]], entities[keys[g.idx[j]]])
+			if err != nil {
+				return err
+			}
+			args = append(args, field, data)
+		}
+		setCmds = append(setCmds, p.HSet(ctx, g.hash, args...))
+		batches = append(batches, g.idx)
+	}
+
+	expireCmds := r.queueHashExpire(ctx, p, groups)
+	_ = execPipeline(ctx, p)
+
+	if err := batchErrors(keys, batches, setCmds); err != nil {
+		if r.opt.Logger != nil {
+			r.opt.Logger.CtxError(ctx, "[redis-cache] HSET exec pipeline failed. err=%v", err)
+		}
+		return err
+	}
+	return r.hashExpireFallback(ctx, groups, expireCmds)
+}
+
+// hashMTouch resets the TTL of the fields, or of their hashes if field TTLs aren't supported
+func (r *RedisCache[K, V]) hashMTouch(ctx context.Context, keys []K) error {
+	groups := r.hashGroups(keys, r.opt.MSetChunkSize)
+	p := r.cli.Pipeline()
+	expireCmds := r.queueHashExpire(ctx, p, groups)
+	if err := execPipeline(ctx, p); err != nil && expireCmds == nil {
+		return err
+	}
+	return r.hashExpireFallback(ctx, groups, expireCmds)
+}
+
+// queueHashExpire adds the TTL commands for the groups to the pipeline.
+// It returns the HPEXPIRE commands to check with hashExpireFallback, or nil if whole-hash TTLs are used.
+func (r *RedisCache[K, V]) queueHashExpire(ctx context.Context, p redis.Pipeliner, groups []hashGroup) []*redis.IntSliceCmd {
+	if r.ttl <= 0 {
+		return nil
+	}
+	if atomic.LoadInt32(&r.hexpire) == hexpireUnsupported {
+		for _, g := range groups {
+			p.PExpire(ctx, g.hash, r.ttl)
+		}
+		return nil
+	}
+	cmds := make([]*redis.IntSliceCmd, 0, len(groups))
+	for _, g := range groups {
+		cmds = append(cmds, p.HPExpire(ctx, g.hash, r.ttl, g.fields...))
+	}
+	return cmds
+}
+
+// hashExpireFallback checks the HPEXPIRE results; if the server doesn't know the command,
+// it switches to whole-hash TTLs for good and applies them to the hashes just written
+func (r *RedisCache[K, V]) hashExpireFallback(ctx context.Context, groups []hashGroup, cmds []*redis.IntSliceCmd) error {
+	for _, cmd := range cmds {
+		err := cmd.Err()
+		if err == nil {
+			atomic.CompareAndSwapInt32(&r.hexpire, hexpireUnknown, hexpireSupported)
+			continue
+		}
+		if !strings.Contains(strings.ToLower(err.Error()), "unknown command") {
+			return err
+		}
+
+		if atomic.SwapInt32(&r.hexpire, hexpireUnsupported) != hexpireUnsupported && r.opt.Logger != nil {
+			r.opt.Logger.CtxInfo(ctx, "[redis-cache] HPEXPIRE not supported, falling back to hash TTLs")
+		}
+		p := r.cli.Pipeline()
+		for _, g := range groups {
+			p.PExpire(ctx, g.hash, r.ttl)
+		}
+		_, err = p.Exec(ctx)
+		return err
+	}
+	return nil
+}
+
+// hashComputeTx runs fn on the key's field of the watched hash and queues its result in a transaction
+func (r *RedisCache[K, V]) hashComputeTx(ctx context.Context, tx *redis.Tx, key K, hash string, fn cacher.ComputeFunc[V]) (V, cacher.Action, error) {
+	var (
+		old   V
+		found bool
+	)
+	field := r.opt.KeyEncoder.EncodeKey(key)
+	data, err := tx.HGet(ctx, hash, field).Bytes()
+	switch {
+	case errors.Is(err, redis.Nil):
+	case err != nil:
+		return old, cacher.ActionKeep, err
+	default:
+		old, _, err = r.decodeValue(key, data)
+		// a value the codec gave up on is treated as missing, and replaced
+		if err != nil && !errors.Is(err, codec.ErrDiscard) {
+			return old, cacher.ActionKeep, err
+		}
+		found = err == nil
+	}
+
+	ret, action := fn(old, found)
+	switch action {
+	case cacher.ActionSet:
+		data, err := r.marshal(key, ret)
+		if err != nil {
+			return ret, action, err
+		}
+		_, err = tx.TxPipelined(ctx, func(p redis.Pipeliner) error {
+			p.HSet(ctx, hash, field, data)
+			return nil
+		})
+		return ret, action, err
+	case cacher.ActionDelete:
+		var zero V
+		_, err = tx.TxPipelined(ctx, func(p redis.Pipeliner) error {
+			p.HDel(ctx, hash, field)
+			return nil
+		})
+		return zero, action, err
+	default:
+		return old, cacher.ActionKeep, nil
+	}
+}
+
+func (r *RedisCache[K, V]) hashMDel(ctx context.Context, keys []K) error {
+	groups := r.hashGroups(keys, r.opt.MSetChunkSize)
+	p := r.cli.Pipeline()
+	cmds := make([]*redis.IntCmd, 0, len(groups))
+	batches := make([][]int, 0, len(groups))
+	for _, g := range groups {
+		cmds = append(cmds, p.HDel(ctx, g.hash, g.fields...))
+		batches = append(batches, g.idx)
+	}
+	_ = execPipeline(ctx, p)
+	return batchErrors(keys, batches, cmds)
+}
+
+func (r *RedisCache[K, V]) hashMExists(ctx context.Context, keys []K) (map[K]bool, error) {
+	p := r.cli.Pipeline()
+	cmds := make([]*redis.BoolCmd, 0, len(keys))
+	for _, k := range keys {
+		cmds = append(cmds, p.HExists(ctx, r.prefix+r.opt.HashBucket(k), r.opt.KeyEncoder.EncodeKey(k)))
+	}
+	if _, err := p.Exec(ctx); err != nil {
+		return nil, err
+	}
+	ret := make(map[K]bool, len(keys))
+	for i, cmd := range cmds {
+		ret[keys[i]] = cmd.Val()
+	}
+	return ret, nil
+}
@@ -54,3 +54,7 @@ func (m *metricsWrapper[K, V]) MDel(ctx context.Context, keys []K) error {
 func (m *metricsWrapper[K, V]) Name() string {
 	return m.next.Name()
 }
+
+func (m *metricsWrapper[K, V]) Unwrap() cacher.Interface[K, V] {
+	return m.next
+}
@@ -1,18 +1,51 @@
 package rediscache
 
 import (
+	"context"
+	"time"
+
 	"github.com/mbeoliero/tiercache/cacher"
 	"github.com/mbeoliero/tiercache/codec"
 )
 
 type Option[K comparable, V any] struct {
-	Codec  codec.Codec[V]
-	Logger Logger
-	Mws    []cacher.Middleware[K, V]
+	Codec codec.Codec[V]
+	// KeyEncoder turns keys into Redis keys and hash fields, see RedisCache.SetKeyEncoder
+	KeyEncoder codec.KeyEncoder[K]
+	Logger     Logger
+	Mws        []cacher.Middleware[K, V]
+	// MaxComputeRetries is how often Compute retries when the key is changed concurrently
+	MaxComputeRetries int
+	// MGetChunkSize is the number of keys per MGET command
+	MGetChunkSize int
+	// MSetChunkSize is the number of keys per MSet script call and per DEL command
+	MSetChunkSize int
+	// HashTag derives the Redis Cluster hash tag of a key, see RedisCache.SetHashTag
+	HashTag func(K) string
+	// HashBucket enables hash mode, see RedisCache.SetHashMode
+	HashBucket func(K) string
+	// SlideThreshold enables sliding expiration on reads, see RedisCache.SetSlidingExpiration
+	SlideThreshold time.Duration
+	// ChunkThreshold is the encoded size above which values are chunked, see RedisCache.SetChunking
+	ChunkThreshold int
+	// ChunkSize is the size of the chunks of a chunked value
+	ChunkSize int
+	// OnCorrupt is called for each value that fails to decode, see RedisCache.SetOnCorrupt
+	OnCorrupt func(ctx context.Context, redisKey string, err error)
+	// DropUndecodable deletes every value that fails to decode, see RedisCache.SetDropUndecodable
+	DropUndecodable bool
+	// Versioned stores a version with each value, see RedisCache.SetVersioning
+	Versioned bool
+	// VersionOf reads the version of back-populated values, see RedisCache.SetVersionOf
+	VersionOf func(V) uint64
 }
 
 func defaultOption[K comparable, V any]() *Option[K, V] {
 	return &Option[K, V]{
-		Codec: &codec.JsonCodec[V]{},
+		Codec:             &codec.JsonCodec[V]{},
+		KeyEncoder:        codec.DefaultKey[K]{},
+		MaxComputeRetries: 16,
+		MGetChunkSize:     500,
+		MSetChunkSize:     500,
 	}
 }
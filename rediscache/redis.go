@@ -3,11 +3,16 @@ package rediscache
 import (
 	"context"
 	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync/atomic"
 	"time"
 
+	"github.com/maypok86/otter/v2"
 	"github.com/mbeoliero/tiercache/cacher"
 	"github.com/mbeoliero/tiercache/codec"
-	"github.com/mbeoliero/tiercache/internal/convert"
 	"github.com/redis/go-redis/v9"
 )
 
@@ -16,6 +21,15 @@ type RedisCache[K comparable, V any] struct {
 	ttl    time.Duration
 	prefix string
 	opt    *Option[K, V]
+
+	// replicas serve MGet if set, see SetReadReplicas
+	replicas *replicaPool
+	// recentWrites holds the keys written within the read-your-writes window
+	recentWrites *otter.Cache[K, struct{}]
+	// corruptEntries counts the values MGet failed to decode
+	corruptEntries atomic.Uint64
+	// hexpire caches whether the server supports field TTLs in hash mode
+	hexpire int32
 }
 
 func NewRedisCache[K comparable, V any](cli redis.UniversalClient, ttl time.Duration) *RedisCache[K, V] {
@@ -31,11 +45,36 @@ func (r *RedisCache[K, V]) SetPrefix(prefix string) *RedisCache[K, V] {
 	return r
 }
 
+// SetKeyEncoder sets how keys are turned into Redis keys, see the encoders in the codec package.
+// The default, codec.DefaultKey, matches earlier versions. Changing the encoder of a cache in use
+// changes its Redis keys, so entries written before are not found anymore.
+func (r *RedisCache[K, V]) SetKeyEncoder(enc codec.KeyEncoder[K]) *RedisCache[K, V] {
+	r.opt.KeyEncoder = enc
+	return r
+}
+
 func (r *RedisCache[K, V]) SetCodec(codec codec.Codec[V]) *RedisCache[K, V] {
 	r.opt.Codec = codec
 	return r
 }
 
+// SetHashTag co-locates related keys in one Redis Cluster hash slot.
+// Keys become prefix + "{" + tag(key) + "}:" + key, so keys with the same tag share a slot
+// and are fetched with a single command.
+func (r *RedisCache[K, V]) SetHashTag(tag func(K) string) *RedisCache[K, V] {
+	r.opt.HashTag = tag
+	return r
+}
+
+// SetChunkSize sets how many keys are sent in one MGET and in one MSet script or DEL call.
+// Larger batches are split into chunks that are sent in a single pipeline. With a redis.ClusterClient
+// the keys are also grouped by hash slot, and the chunks of each node are sent to the nodes in parallel.
+func (r *RedisCache[K, V]) SetChunkSize(mgetChunkSize, msetChunkSize int) *RedisCache[K, V] {
+	r.opt.MGetChunkSize = mgetChunkSize
+	r.opt.MSetChunkSize = msetChunkSize
+	return r
+}
+
 func (r *RedisCache[K, V]) SetLogger(logger Logger) *RedisCache[K, V] {
 	r.opt.Logger = logger
 	return r
@@ -56,95 +95,268 @@ func (r *RedisCache[K, V]) MGet(ctx context.Context, keys []K) (map[K]V, []K, er
 	if len(keys) == 0 {
 		return ret, miss, nil
 	}
+	if r.replicas != nil {
+		return r.mgetReplicas(ctx, keys)
+	}
+	return r.mget(ctx, r.cli, keys)
+}
+
+// mget reads the keys with the client, which is the primary or one of the read replicas
+func (r *RedisCache[K, V]) mget(ctx context.Context, cli redis.UniversalClient, keys []K) (map[K]V, []K, error) {
+	ret := make(map[K]V, len(keys))
+	miss := make([]K, 0)
+	if r.opt.HashBucket != nil {
+		return r.hashMGet(ctx, cli, keys)
+	}
 	redisKeys := r.getRedisKeys(keys)
 	if r.opt.Logger != nil {
 		r.opt.Logger.CtxDebug(ctx, "[redis-cache] read data from redis keys=%v", redisKeys)
 	}
 
-	p := r.cli.Pipeline()
-	for _, key := range redisKeys {
-		p.Get(ctx, key)
+	// one MGET per batch, all batches in a single pipeline
+	p := cli.Pipeline()
+	// replicas are read-only, so their TTLs are extended through the primary
+	slideP := p
+	if cli != r.cli {
+		slideP = r.cli.Pipeline()
 	}
-	execResult, err := p.Exec(ctx)
-	if err != nil && !errors.Is(err, redis.Nil) {
-		if r.opt.Logger != nil {
-			r.opt.Logger.CtxError(ctx, "[redis-cache] MGet exec pipeline failed. err=%v", err)
+	batches := batches(cli, redisKeys, r.opt.MGetChunkSize)
+	slideBatches := batches
+	if cli != r.cli {
+		slideBatches = r.batches(redisKeys, r.opt.MGetChunkSize)
+	}
+	cmds := make([]*redis.SliceCmd, 0, len(batches))
+	slideCmds := make([]*redis.Cmd, 0, len(slideBatches))
+	for _, b := range batches {
+		cmds = append(cmds, p.MGet(ctx, pick(redisKeys, b)...))
+	}
+	for _, b := range slideBatches {
+		if cmd := r.queueSlide(ctx, slideP, pick(redisKeys, b)); cmd != nil {
+			slideCmds = append(slideCmds, cmd)
+		}
+	}
+	// errors are checked per batch, so a failing node only fails its own keys
+	_ = execPipeline(ctx, p)
+	if slideP != p && len(slideCmds) > 0 {
+		_ = execPipeline(ctx, slideP)
+	}
+	for _, cmd := range slideCmds {
+		if err := cmd.Err(); err != nil && r.opt.Logger != nil {
+			r.opt.Logger.CtxError(ctx, "[redis-cache] sliding expiration failed. err=%v", err)
 		}
-		return nil, nil, err
 	}
 
-	for index, result := range execResult {
-		value, err := result.(*redis.StringCmd).Result()
-		if err != nil {
-			if errors.Is(err, redis.Nil) {
-				continue
-			}
+	var (
+		failed    map[K]error
+		failedCmd int
+		lastErr   error
+		chunked   []chunkRead
+		discards  []discarded
+	)
+	for i, cmd := range cmds {
+		if err := cmd.Err(); err != nil {
 			if r.opt.Logger != nil {
-				r.opt.Logger.CtxError(ctx, "[redis-cache] get redis failed. err=%v", err)
+				r.opt.Logger.CtxError(ctx, "[redis-cache] MGet batch failed. err=%v", err)
+			}
+			if failed == nil {
+				failed = make(map[K]error)
+			}
+			for _, idx := range batches[i] {
+				failed[keys[idx]] = err
 			}
+			failedCmd++
+			lastErr = err
 			continue
 		}
 
-		var entity V
-		if err = r.opt.Codec.Unmarshal([]byte(value), &entity); err != nil {
-			if r.opt.Logger != nil {
-				r.opt.Logger.CtxError(ctx, "[redis-cache] unmarshall failed. val=%value,err=%value", value, err)
+		for j, result := range cmd.Val() {
+			value, ok := result.(string)
+			if !ok {
+				continue
 			}
-			continue
+			if m, ok := parseManifest(value); ok {
+				chunked = append(chunked, chunkRead{idx: batches[i][j], redisKey: redisKeys[batches[i][j]], m: m})
+				continue
+			}
+
+			entity, _, err := r.decodeValue(keys[batches[i][j]], []byte(value))
+			if err != nil {
+				if r.corrupt(ctx, redisKeys[batches[i][j]], err) {
+					discards = append(discards, discarded{redisKey: redisKeys[batches[i][j]], value: value})
+				}
+				continue
+			}
+			ret[keys[batches[i][j]]] = entity
+		}
+	}
+	if failedCmd == len(cmds) {
+		return nil, nil, lastErr
+	}
+
+	if len(chunked) > 0 {
+		data, errs := r.readChunks(ctx, cli, chunked)
+		for _, read := range chunked {
+			err, readFailed := errs[read.idx]
+			value, found := data[read.idx]
+			switch {
+			case readFailed && !errors.Is(err, errChunks):
+				if failed == nil {
+					failed = make(map[K]error)
+				}
+				failed[keys[read.idx]] = err
+				continue
+			case !readFailed && !found:
+				// replaced while it was read
+				continue
+			}
+			var entity V
+			if found {
+				entity, _, err = r.decodeValue(keys[read.idx], value)
+			}
+			if err != nil {
+				if r.corrupt(ctx, read.redisKey, err) {
+					discards = append(discards, discarded{
+						redisKey: read.redisKey,
+						value:    read.m.String(),
+						chunks:   read.m.chunkKeys(read.redisKey),
+					})
+				}
+				continue
+			}
+			ret[keys[read.idx]] = entity
 		}
-		ret[keys[index]] = entity
 	}
+	r.discard(ctx, discards)
 
 	if r.opt.Logger != nil {
 		r.opt.Logger.CtxDebug(ctx, "[redis-cache] read data from redis keys=%v. ret=%v", redisKeys, ret)
 	}
 
 	for _, key := range keys {
-		if _, ok := ret[key]; !ok {
+		if _, ok := ret[key]; ok {
+			continue
+		}
+		if _, ok := failed[key]; !ok {
 			miss = append(miss, key)
 		}
 	}
+	if len(failed) > 0 {
+		return ret, miss, cacher.NewPartialError(failed)
+	}
 	return ret, miss, nil
 }
 
+// MExists reports which of the keys exist in Redis, using one EXISTS per key in a pipeline
+func (r *RedisCache[K, V]) MExists(ctx context.Context, keys []K) (map[K]bool, error) {
+	ret := make(map[K]bool, len(keys))
+	if len(keys) == 0 {
+		return ret, nil
+	}
+	if r.opt.HashBucket != nil {
+		return r.hashMExists(ctx, keys)
+	}
+
+	p := r.cli.Pipeline()
+	cmds := make([]*redis.IntCmd, 0, len(keys))
+	for _, key := range keys {
+		cmds = append(cmds, p.Exists(ctx, r.getRedisKey(key)))
+	}
+	if _, err := p.Exec(ctx); err != nil {
+		if r.opt.Logger != nil {
+			r.opt.Logger.CtxError(ctx, "[redis-cache] MExists exec pipeline failed. err=%v", err)
+		}
+		return nil, err
+	}
+	for i, cmd := range cmds {
+		ret[keys[i]] = cmd.Val() > 0
+	}
+	return ret, nil
+}
+
 func (r *RedisCache[K, V]) MSet(ctx context.Context, entities map[K]V) error {
 	if len(entities) == 0 {
 		return nil
 	}
-	p := r.cli.Pipeline()
+	defer r.markWritten(maps.Keys(entities))
+	if r.opt.HashBucket != nil {
+		return r.hashMSet(ctx, entities)
+	}
+	if r.opt.Versioned && cacher.IsBackfill(ctx) {
+		return r.msetMissing(ctx, entities)
+	}
+
+	keys := make([]K, 0, len(entities))
+	redisKeys := make([]string, 0, len(entities))
+	values := make([]any, 0, len(entities))
 	for key, entity := range entities {
-		data, err := r.opt.Codec.Marshal(entity)
+		// versioned values get their version prefix from the script
+		data, err := r.marshal(key, entity)
 		if err != nil {
 			return err
 		}
-		p.SetEx(ctx, r.getRedisKey(key), data, r.ttl)
+		keys = append(keys, key)
+		redisKeys = append(redisKeys, r.getRedisKey(key))
+		values = append(values, data)
+	}
+
+	script := setScript
+	if r.opt.Versioned {
+		script = versionedSetScript
+	}
+	chunking := r.chunkingEnabled()
+	if chunking {
+		script = swapSetScript
+	}
+
+	// one script call per batch, all batches in a single pipeline
+	p := r.cli.Pipeline()
+	var (
+		batches  [][]int
+		cmds     []*redis.Cmd
+		smallIdx = make([]int, 0, len(redisKeys))
+	)
+	// values above the chunking threshold get a script call of their own
+	for i, redisKey := range redisKeys {
+		if data := values[i].([]byte); chunking && len(data) > r.opt.ChunkThreshold {
+			batches = append(batches, []int{i})
+			cmds = append(cmds, r.queueChunkedSet(ctx, p, redisKey, data))
+		} else {
+			smallIdx = append(smallIdx, i)
+		}
+	}
+	for _, b := range r.batches(pick(redisKeys, smallIdx), r.opt.MSetChunkSize) {
+		b = pick(smallIdx, b)
+		args := make([]any, 0, len(b)+2)
+		args = append(args, r.ttl.Milliseconds())
+		if chunking {
+			args = append(args, chunkMagic)
+		}
+		args = append(args, pick(values, b)...)
+		batches = append(batches, b)
+		cmds = append(cmds, script.Eval(ctx, p, pick(redisKeys, b), args...))
+	}
+	_ = execPipeline(ctx, p)
+	if chunking {
+		r.dropReplacedChunks(ctx, redisKeys, batches, cmds)
 	}
-	results, err := p.Exec(ctx)
-	if err != nil {
+	if err := batchErrors(keys, batches, cmds); err != nil {
 		if r.opt.Logger != nil {
 			r.opt.Logger.CtxError(ctx, "[redis-cache] MSet exec pipeline failed. err=%v", err)
 		}
 		return err
 	}
-	for _, result := range results {
-		if _, err = result.(*redis.StatusCmd).Result(); err != nil {
-			if r.opt.Logger != nil {
-				r.opt.Logger.CtxError(ctx, "[redis-cache] set result failed. err=%v", err)
-			}
-			return err
-		}
-	}
 	if r.opt.Logger != nil {
 		r.opt.Logger.CtxDebug(ctx, "[redis-cache] set to redis success. size=%v", len(entities))
 	}
 	return nil
 }
 
-func (r *RedisCache[K, T]) MDel(ctx context.Context, keys []K) error {
+func (r *RedisCache[K, V]) MDel(ctx context.Context, keys []K) error {
 	if len(keys) == 0 {
 		return nil
 	}
-	if err := r.cli.Del(ctx, r.getRedisKeys(keys)...).Err(); err != nil {
+	defer r.markWritten(slices.Values(keys))
+	if err := r.mdel(ctx, keys); err != nil {
 		if r.opt.Logger != nil {
 			r.opt.Logger.CtxError(ctx, "[redis-cache] delete failed.keys=%v,err=%v", keys, err)
 		}
@@ -157,6 +369,80 @@ func (r *RedisCache[K, T]) MDel(ctx context.Context, keys []K) error {
 	return nil
 }
 
+func (r *RedisCache[K, V]) mdel(ctx context.Context, keys []K) error {
+	if r.opt.HashBucket != nil {
+		return r.hashMDel(ctx, keys)
+	}
+	redisKeys := r.getRedisKeys(keys)
+	p := r.cli.Pipeline()
+	batches := r.batches(redisKeys, r.opt.MSetChunkSize)
+	if r.chunkingEnabled() {
+		// the deleted manifests are returned so their chunks can be dropped as well
+		cmds := make([]*redis.Cmd, 0, len(batches))
+		for _, b := range batches {
+			cmds = append(cmds, swapDelScript.Eval(ctx, p, pick(redisKeys, b), chunkMagic))
+		}
+		_ = execPipeline(ctx, p)
+		r.dropReplacedChunks(ctx, redisKeys, batches, cmds)
+		return batchErrors(keys, batches, cmds)
+	}
+	cmds := make([]*redis.IntCmd, 0, len(batches))
+	for _, b := range batches {
+		cmds = append(cmds, p.Del(ctx, pick(redisKeys, b)...))
+	}
+	_ = execPipeline(ctx, p)
+	return batchErrors(keys, batches, cmds)
+}
+
+// execPipeline runs the pipeline. When go-redis gives up getting a connection it may leave the commands
+// without an error, so the error is copied to them to keep the per-command checks reliable.
+func execPipeline(ctx context.Context, p redis.Pipeliner) error {
+	cmds, err := p.Exec(ctx)
+	if err == nil {
+		return nil
+	}
+	for _, cmd := range cmds {
+		if cmd.Err() != nil {
+			return err
+		}
+	}
+	for _, cmd := range cmds {
+		cmd.SetErr(err)
+	}
+	return err
+}
+
+// batchErrors collects the errors of the batch commands. It returns the error itself when every batch failed,
+// and a *cacher.PartialError with the keys of the failed batches when only some did.
+func batchErrors[K comparable, C redis.Cmder](keys []K, batches [][]int, cmds []C) error {
+	var (
+		failed  map[K]error
+		lastErr error
+		nFailed int
+	)
+	for i, cmd := range cmds {
+		err := cmd.Err()
+		if err == nil {
+			continue
+		}
+		if failed == nil {
+			failed = make(map[K]error)
+		}
+		for _, idx := range batches[i] {
+			failed[keys[idx]] = err
+		}
+		lastErr = err
+		nFailed++
+	}
+	if nFailed == 0 {
+		return nil
+	}
+	if nFailed == len(cmds) {
+		return lastErr
+	}
+	return cacher.NewPartialError(failed)
+}
+
 func (r *RedisCache[K, T]) getRedisKeys(keys []K) []string {
 	ret := make([]string, 0, len(keys))
 	for _, k := range keys {
@@ -166,7 +452,56 @@ func (r *RedisCache[K, T]) getRedisKeys(keys []K) []string {
 }
 
 func (r *RedisCache[K, T]) getRedisKey(k K) string {
-	return r.prefix + convert.ToString(k)
+	if r.opt.HashTag != nil {
+		return r.prefix + "{" + r.opt.HashTag(k) + "}:" + r.opt.KeyEncoder.EncodeKey(k)
+	}
+	return r.prefix + r.opt.KeyEncoder.EncodeKey(k)
+}
+
+// marshal encodes a value, passing its codec key to a codec.KeyedCodec
+func (r *RedisCache[K, V]) marshal(k K, v V) ([]byte, error) {
+	if kc, ok := r.opt.Codec.(codec.KeyedCodec[V]); ok {
+		return kc.MarshalKey(r.codecKey(k), v)
+	}
+	return r.opt.Codec.Marshal(v)
+}
+
+// unmarshal decodes a value, passing its codec key to a codec.KeyedCodec
+func (r *RedisCache[K, V]) unmarshal(k K, data []byte, v *V) error {
+	if kc, ok := r.opt.Codec.(codec.KeyedCodec[V]); ok {
+		return kc.UnmarshalKey(r.codecKey(k), data, v)
+	}
+	return r.opt.Codec.Unmarshal(data, v)
+}
+
+// codecKey identifies a value to a codec.KeyedCodec. It doesn't depend on the hash tag or hash mode,
+// so values stay readable when those change.
+func (r *RedisCache[K, V]) codecKey(k K) string {
+	return r.prefix + r.opt.KeyEncoder.EncodeKey(k)
+}
+
+// DecodeKey recovers the key from a Redis key of this cache, or from a hash field in hash mode.
+// It needs a key encoder that implements codec.KeyDecoder.
+func (r *RedisCache[K, V]) DecodeKey(redisKey string) (K, error) {
+	var zero K
+	dec, ok := r.opt.KeyEncoder.(codec.KeyDecoder[K])
+	if !ok {
+		return zero, codec.ErrKeyNotDecodable
+	}
+	if r.opt.HashBucket != nil {
+		return dec.DecodeKey(redisKey)
+	}
+	s, ok := strings.CutPrefix(redisKey, r.prefix)
+	if !ok {
+		return zero, fmt.Errorf("%w: %q has no prefix %q", codec.ErrKeyNotDecodable, redisKey, r.prefix)
+	}
+	if r.opt.HashTag != nil {
+		_, s, ok = strings.Cut(s, "}:")
+		if !ok {
+			return zero, fmt.Errorf("%w: %q has no hash tag", codec.ErrKeyNotDecodable, redisKey)
+		}
+	}
+	return dec.DecodeKey(s)
 }
 
 func (r *RedisCache[K, V]) Name() string {
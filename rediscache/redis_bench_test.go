@@ -0,0 +1,62 @@
+package rediscache
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+type benchValue struct {
+	ID    int
+	Name  string
+	Score float64
+}
+
+func benchEntities(n int) (map[int]benchValue, []int) {
+	entities := make(map[int]benchValue, n)
+	keys := make([]int, 0, n)
+	for i := 0; i < n; i++ {
+		entities[i] = benchValue{ID: i, Name: fmt.Sprintf("name-%d", i), Score: float64(i) / 3}
+		keys = append(keys, i)
+	}
+	return entities, keys
+}
+
+// A chunk size of 1 sends one command per key, like the former per-key pipelines.
+func BenchmarkMGet(b *testing.B) {
+	_, rdb := setupRedis(b)
+	ctx := context.TODO()
+	entities, keys := benchEntities(2000)
+	if err := NewRedisCache[int, benchValue](rdb, time.Hour).MSet(ctx, entities); err != nil {
+		b.Fatal(err)
+	}
+
+	for _, size := range []int{1, 100, 500} {
+		c := NewRedisCache[int, benchValue](rdb, time.Hour).SetChunkSize(size, size)
+		b.Run(fmt.Sprintf("chunk-%d", size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, _, err := c.MGet(ctx, keys); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkMSet(b *testing.B) {
+	_, rdb := setupRedis(b)
+	ctx := context.TODO()
+	entities, _ := benchEntities(2000)
+
+	for _, size := range []int{1, 100, 500} {
+		c := NewRedisCache[int, benchValue](rdb, time.Hour).SetChunkSize(size, size)
+		b.Run(fmt.Sprintf("chunk-%d", size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if err := c.MSet(ctx, entities); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,631 @@
+package rediscache
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/mbeoliero/tiercache/cacher"
+	"github.com/mbeoliero/tiercache/codec"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupRedis(t testing.TB) (*miniredis.Miniredis, redis.UniversalClient) {
+	s, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.Close)
+	return s, redis.NewClient(&redis.Options{
+		Addr: s.Addr(),
+	})
+}
+
+func TestVersioning(t *testing.T) {
+	_, rdb := setupRedis(t)
+	ctx := context.TODO()
+	c := NewRedisCache[string, string](rdb, time.Hour).SetPrefix("v:").SetVersioning(true)
+
+	// every write increments the version
+	assert.Nil(t, c.MSet(ctx, map[string]string{"a": "1"}))
+	assert.Nil(t, c.MSet(ctx, map[string]string{"a": "2"}))
+	v, ver, ok, err := c.GetWithVersion(ctx, "a")
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "2", v)
+	assert.Equal(t, uint64(2), ver)
+
+	// stale writers can't overwrite newer data
+	stored, err := c.CompareAndSet(ctx, "a", "stale", 1)
+	assert.Nil(t, err)
+	assert.False(t, stored)
+	stored, err = c.CompareAndSet(ctx, "a", "newer", 10)
+	assert.Nil(t, err)
+	assert.True(t, stored)
+
+	found, _, err := c.MGet(ctx, []string{"a"})
+	assert.Nil(t, err)
+	assert.Equal(t, "newer", found["a"])
+
+	// back-population doesn't replace an existing value
+	assert.Nil(t, c.MSet(cacher.WithBackfill(ctx), map[string]string{"a": "from-db", "b": "from-db"}))
+	v, ver, _, _ = c.GetWithVersion(ctx, "a")
+	assert.Equal(t, "newer", v)
+	assert.Equal(t, uint64(10), ver)
+	v, ver, _, _ = c.GetWithVersion(ctx, "b")
+	assert.Equal(t, "from-db", v)
+	assert.Equal(t, uint64(1), ver)
+
+	deleted, err := c.CompareAndDelete(ctx, "a", 9)
+	assert.Nil(t, err)
+	assert.False(t, deleted)
+	deleted, err = c.CompareAndDelete(ctx, "a", 10)
+	assert.Nil(t, err)
+	assert.True(t, deleted)
+
+	// compute keeps the version monotonic
+	_, _, err = c.Compute(ctx, "b", func(old string, found bool) (string, cacher.Action) {
+		return old + "!", cacher.ActionSet
+	})
+	assert.Nil(t, err)
+	v, ver, _, _ = c.GetWithVersion(ctx, "b")
+	assert.Equal(t, "from-db!", v)
+	assert.Equal(t, uint64(2), ver)
+}
+
+func TestVersionedBackfill(t *testing.T) {
+	_, rdb := setupRedis(t)
+	ctx := context.TODO()
+	type row struct {
+		Name     string
+		Revision uint64
+	}
+	c := NewRedisCache[string, row](rdb, time.Hour).SetPrefix("v:").SetVersioning(true).
+		SetVersionOf(func(r row) uint64 { return r.Revision })
+
+	// back-populated values keep the version of the source
+	assert.Nil(t, c.MSet(cacher.WithBackfill(ctx), map[string]row{"a": {"db", 5}, "b": {"db", 5}}))
+	v, ver, _, _ := c.GetWithVersion(ctx, "a")
+	assert.Equal(t, row{"db", 5}, v)
+	assert.Equal(t, uint64(5), ver)
+
+	// so a delayed change event can't replace them with older data
+	stored, err := c.CompareAndSet(ctx, "a", row{"event", 3}, 3)
+	assert.Nil(t, err)
+	assert.False(t, stored)
+
+	// and they only replace older values
+	_, err = c.CompareAndSet(ctx, "b", row{"event", 7}, 7)
+	assert.Nil(t, err)
+	assert.Nil(t, c.MSet(cacher.WithBackfill(ctx), map[string]row{"a": {"db", 6}, "b": {"db", 6}}))
+	v, ver, _, _ = c.GetWithVersion(ctx, "a")
+	assert.Equal(t, row{"db", 6}, v)
+	assert.Equal(t, uint64(6), ver)
+	v, ver, _, _ = c.GetWithVersion(ctx, "b")
+	assert.Equal(t, row{"event", 7}, v)
+	assert.Equal(t, uint64(7), ver)
+}
+
+func TestChunkedMGetMSet(t *testing.T) {
+	s, rdb := setupRedis(t)
+	ctx := context.TODO()
+	c := NewRedisCache[int, int](rdb, time.Hour).SetPrefix("c:").SetChunkSize(3, 4)
+
+	entities := make(map[int]int)
+	keys := make([]int, 0, 11)
+	for i := 0; i < 10; i++ {
+		entities[i] = i * i
+		keys = append(keys, i)
+	}
+	keys = append(keys, 99)
+	assert.Nil(t, c.MSet(ctx, entities))
+	assert.Equal(t, time.Hour, s.TTL("c:7"))
+
+	found, miss, err := c.MGet(ctx, keys)
+	assert.Nil(t, err)
+	assert.Equal(t, entities, found)
+	assert.Equal(t, []int{99}, miss)
+}
+
+func TestHashSlot(t *testing.T) {
+	assert.Equal(t, uint16(0x31C3), crc16("123456789"))
+	assert.Equal(t, 12182, hashSlot("foo"))
+	assert.Equal(t, hashSlot("{user1000}.following"), hashSlot("{user1000}.followers"))
+	assert.Equal(t, hashSlot("foo{}{bar}"), hashSlot("foo{}{bar}"))
+	assert.NotEqual(t, hashSlot("foo{}{bar}"), hashSlot("bar"))
+}
+
+func TestClusterClient(t *testing.T) {
+	s, _ := setupRedis(t)
+	rdb := redis.NewClusterClient(&redis.ClusterOptions{
+		Addrs: []string{s.Addr()},
+	})
+	ctx := context.TODO()
+	c := NewRedisCache[int, int](rdb, time.Hour).SetPrefix("user:").SetChunkSize(2, 2).SetHashTag(func(k int) string {
+		return "tenant"
+	})
+
+	entities := map[int]int{1: 1, 2: 2, 3: 3, 4: 4, 5: 5}
+	assert.Nil(t, c.MSet(ctx, entities))
+	assert.True(t, s.Exists("user:{tenant}:3"))
+	assert.Len(t, c.batches(c.getRedisKeys([]int{1, 2, 3, 4, 5}), 2), 3)
+
+	found, miss, err := c.MGet(ctx, []int{1, 2, 3, 4, 5, 6})
+	assert.Nil(t, err)
+	assert.Equal(t, entities, found)
+	assert.Equal(t, []int{6}, miss)
+
+	assert.Nil(t, c.MDel(ctx, []int{1, 2, 3}))
+	found, _, err = c.MGet(ctx, []int{1, 2, 3, 4, 5})
+	assert.Nil(t, err)
+	assert.Equal(t, map[int]int{4: 4, 5: 5}, found)
+}
+
+func TestRing(t *testing.T) {
+	a, b := miniredis.RunT(t), miniredis.RunT(t)
+	ring := redis.NewRing(&redis.RingOptions{Addrs: map[string]string{"a": a.Addr(), "b": b.Addr()}})
+	t.Cleanup(func() { _ = ring.Close() })
+	ctx := context.TODO()
+	large := strings.Repeat("0123456789", 5)
+	c := NewRedisCache[int, string](ring, time.Minute).SetPrefix("r:").SetChunking(32, 16)
+
+	entities := make(map[int]string)
+	keys := make([]int, 0, 20)
+	for i := range 20 {
+		entities[i] = strconv.Itoa(i)
+		if i%5 == 0 {
+			entities[i] = large
+		}
+		keys = append(keys, i)
+	}
+	assert.Nil(t, c.MSet(ctx, entities))
+	assert.NotEmpty(t, a.Keys())
+	assert.NotEmpty(t, b.Keys())
+
+	// every key is on the shard the ring routes it to
+	for i := range 20 {
+		if i%5 != 0 {
+			v, err := ring.Get(ctx, "r:"+strconv.Itoa(i)).Result()
+			assert.Nil(t, err)
+			assert.Equal(t, strconv.Quote(entities[i]), v)
+		}
+	}
+	ret, miss, err := c.MGet(ctx, keys)
+	assert.Nil(t, err)
+	assert.Equal(t, entities, ret)
+	assert.Empty(t, miss)
+	for _, k := range keys {
+		ret, _, err = c.MGet(ctx, []int{k})
+		assert.Nil(t, err)
+		assert.Equal(t, entities[k], ret[k])
+	}
+
+	assert.Nil(t, c.MDel(ctx, keys))
+	assert.Empty(t, a.Keys())
+	assert.Empty(t, b.Keys())
+}
+
+func TestBatchErrors(t *testing.T) {
+	ctx := context.TODO()
+	ok, failed := redis.NewIntCmd(ctx), redis.NewIntCmd(ctx)
+	failed.SetErr(errors.New("node down"))
+	keys := []string{"a", "b", "c"}
+	batches := [][]int{{0, 1}, {2}}
+
+	assert.Nil(t, batchErrors(keys, batches, []*redis.IntCmd{ok, ok}))
+	assert.EqualError(t, batchErrors(keys, batches, []*redis.IntCmd{failed, failed}), "node down")
+
+	var partialErr *cacher.PartialError[string]
+	assert.ErrorAs(t, batchErrors(keys, batches, []*redis.IntCmd{ok, failed}), &partialErr)
+	assert.Equal(t, []string{"c"}, partialErr.Keys())
+}
+
+func TestHashMode(t *testing.T) {
+	s, rdb := setupRedis(t)
+	ctx := context.TODO()
+	c := NewRedisCache[int, string](rdb, time.Minute).SetPrefix("settings:").
+		SetHashMode(func(k int) string { return strconv.Itoa(k / 100) })
+
+	assert.Nil(t, c.MSet(ctx, map[int]string{1: "a", 2: "b", 101: "c"}))
+	assert.ElementsMatch(t, []string{"settings:0", "settings:1"}, s.Keys())
+	assert.Equal(t, "\"a\"", s.HGet("settings:0", "1"))
+
+	// miniredis has no HPEXPIRE, so the TTL falls back to the whole hash
+	assert.Equal(t, time.Minute, s.TTL("settings:0"))
+	assert.Equal(t, hexpireUnsupported, c.hexpire)
+
+	ret, miss, err := c.MGet(ctx, []int{1, 2, 3, 101})
+	assert.Nil(t, err)
+	assert.Equal(t, map[int]string{1: "a", 2: "b", 101: "c"}, ret)
+	assert.Equal(t, []int{3}, miss)
+
+	exists, err := c.MExists(ctx, []int{1, 3})
+	assert.Nil(t, err)
+	assert.Equal(t, map[int]bool{1: true, 3: false}, exists)
+
+	assert.Nil(t, c.MDel(ctx, []int{1, 101}))
+	ret, _, err = c.MGet(ctx, []int{1, 2, 101})
+	assert.Nil(t, err)
+	assert.Equal(t, map[int]string{2: "b"}, ret)
+
+	v, action, err := c.Compute(ctx, 2, func(old string, found bool) (string, cacher.Action) {
+		assert.True(t, found)
+		return old + "!", cacher.ActionSet
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, cacher.ActionSet, action)
+	assert.Equal(t, "b!", v)
+	assert.Equal(t, "\"b!\"", s.HGet("settings:0", "2"))
+	_, action, err = c.Compute(ctx, 3, func(old string, found bool) (string, cacher.Action) {
+		assert.False(t, found)
+		return "", cacher.ActionDelete
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, cacher.ActionDelete, action)
+
+	// a write to another field of the watched hash between the read and the write causes a retry
+	calls := 0
+	v, _, err = c.Compute(ctx, 2, func(old string, found bool) (string, cacher.Action) {
+		if calls++; calls == 1 {
+			s.HSet("settings:0", "4", "\"d\"")
+		}
+		return "c", cacher.ActionSet
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, "c", v)
+
+	s.FastForward(2 * time.Minute)
+	ret, _, err = c.MGet(ctx, []int{2})
+	assert.Nil(t, err)
+	assert.Empty(t, ret)
+}
+
+func TestSlidingExpiration(t *testing.T) {
+	s, rdb := setupRedis(t)
+	ctx := context.TODO()
+	c := NewRedisCache[string, string](rdb, time.Minute).SetPrefix("s:").SetSlidingExpiration(30 * time.Second)
+	assert.Nil(t, c.MSet(ctx, map[string]string{"a": "1", "b": "2"}))
+
+	// above the threshold the TTL is left alone
+	s.FastForward(20 * time.Second)
+	_, _, err := c.MGet(ctx, []string{"a"})
+	assert.Nil(t, err)
+	assert.Equal(t, 40*time.Second, s.TTL("s:a"))
+
+	// below it the TTL is reset, but only for the keys read
+	s.FastForward(15 * time.Second)
+	_, _, err = c.MGet(ctx, []string{"a", "missing"})
+	assert.Nil(t, err)
+	assert.Equal(t, time.Minute, s.TTL("s:a"))
+	assert.Equal(t, 25*time.Second, s.TTL("s:b"))
+
+	assert.Nil(t, c.MTouch(ctx, []string{"b", "missing"}))
+	assert.Equal(t, time.Minute, s.TTL("s:b"))
+	assert.False(t, s.Exists("s:missing"))
+}
+
+func TestReadReplicas(t *testing.T) {
+	primary, rdb := setupRedis(t)
+	replica1, rep1 := setupRedis(t)
+	replica2, rep2 := setupRedis(t)
+	ctx := context.TODO()
+	c := NewRedisCache[string, string](rdb, time.Hour).SetPrefix("r:").SetReadReplicas(ReadRoundRobin, rep1, rep2)
+
+	// the replicas lag behind the primary
+	assert.Nil(t, c.MSet(ctx, map[string]string{"a": "new"}))
+	assert.Nil(t, replica1.Set("r:a", `"old1"`))
+	assert.Nil(t, replica2.Set("r:a", `"old2"`))
+	seen := map[string]bool{}
+	for range 4 {
+		ret, _, err := c.MGet(ctx, []string{"a"})
+		assert.Nil(t, err)
+		seen[ret["a"]] = true
+	}
+	assert.Equal(t, map[string]bool{"old1": true, "old2": true}, seen)
+	assert.Equal(t, `"new"`, mustGet(t, primary, "r:a"))
+
+	// within the read-your-writes window the primary is read
+	c.SetReadYourWrites(time.Minute)
+	assert.Nil(t, c.MSet(ctx, map[string]string{"a": "newer"}))
+	ret, _, err := c.MGet(ctx, []string{"a", "b"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"a": "newer"}, ret)
+
+	// keys a failing replica can't read are read from the primary
+	assert.Nil(t, primary.Set("r:b", `"b"`))
+	replica1.Close()
+	replica2.Close()
+	ret, miss, err := c.MGet(ctx, []string{"b", "c"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"b": "b"}, ret)
+	assert.Equal(t, []string{"c"}, miss)
+}
+
+func mustGet(t *testing.T, s *miniredis.Miniredis, key string) string {
+	v, err := s.Get(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestLowestLatencyPolicy(t *testing.T) {
+	pool := &replicaPool{policy: ReadLowestLatency}
+	for _, d := range []time.Duration{3 * time.Millisecond, time.Millisecond, 2 * time.Millisecond} {
+		rep := &replica{}
+		rep.observe(d)
+		pool.replicas = append(pool.replicas, rep)
+	}
+	var best int
+	for range 1000 {
+		if pool.pick() == pool.replicas[1] {
+			best++
+		}
+	}
+	assert.Greater(t, best, 900)
+
+	pool.replicas[1].penalize()
+	assert.Equal(t, time.Second, time.Duration(pool.replicas[1].latency.Load()))
+}
+
+func TestChunking(t *testing.T) {
+	s, rdb := setupRedis(t)
+	ctx := context.TODO()
+	c := NewRedisCache[string, string](rdb, time.Hour).SetPrefix("c:").SetChunking(16, 8)
+	large := strings.Repeat("0123456789", 5)
+
+	assert.Nil(t, c.MSet(ctx, map[string]string{"big": large, "small": "x"}))
+	// "big" is 52 bytes encoded: a manifest and 7 chunks
+	assert.Len(t, s.Keys(), 9)
+	assert.True(t, strings.HasPrefix(mustGet(t, s, "c:big"), chunkMagic))
+	ret, miss, err := c.MGet(ctx, []string{"big", "small", "none"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"big": large, "small": "x"}, ret)
+	assert.Equal(t, []string{"none"}, miss)
+
+	// overwrites drop the old chunks
+	assert.Nil(t, c.MSet(ctx, map[string]string{"big": large + large}))
+	assert.Len(t, s.Keys(), 15)
+	assert.Nil(t, c.MSet(ctx, map[string]string{"big": "y"}))
+	assert.Len(t, s.Keys(), 2)
+
+	// so do deletes
+	assert.Nil(t, c.MSet(ctx, map[string]string{"big": large}))
+	assert.Nil(t, c.MDel(ctx, []string{"big", "small"}))
+	assert.Empty(t, s.Keys())
+
+	// a value with a corrupt or missing chunk is a miss, and is deleted with its chunks
+	var reported []string
+	c.SetOnCorrupt(func(ctx context.Context, redisKey string, err error) {
+		assert.ErrorIs(t, err, errChunks)
+		reported = append(reported, redisKey)
+	})
+	for _, damage := range []func(chunk string){
+		func(chunk string) { assert.Nil(t, s.Set(chunk, "garbage!")) },
+		func(chunk string) { s.Del(chunk) },
+	} {
+		assert.Nil(t, c.MSet(ctx, map[string]string{"big": large}))
+		m, ok := parseManifest(mustGet(t, s, "c:big"))
+		assert.True(t, ok)
+		damage(m.chunkKeys("c:big")[2])
+		ret, miss, err = c.MGet(ctx, []string{"big"})
+		assert.Nil(t, err)
+		assert.Empty(t, ret)
+		assert.Equal(t, []string{"big"}, miss)
+		assert.Empty(t, s.Keys())
+	}
+	assert.Equal(t, []string{"c:big", "c:big"}, reported)
+	assert.Equal(t, uint64(2), c.CorruptEntries())
+
+	// Compute replaces a chunked value and its chunks
+	assert.Nil(t, c.MSet(ctx, map[string]string{"big": large}))
+	v, _, err := c.Compute(ctx, "big", func(old string, found bool) (string, cacher.Action) {
+		assert.True(t, found)
+		assert.Equal(t, large, old)
+		return "z", cacher.ActionSet
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "z", v)
+	assert.Equal(t, []string{"c:big"}, s.Keys())
+}
+
+func TestChunksReplacedDuringRead(t *testing.T) {
+	s, rdb := setupRedis(t)
+	ctx := context.TODO()
+	c := NewRedisCache[string, string](rdb, time.Hour).SetPrefix("c:").SetChunking(16, 8)
+	large := strings.Repeat("0123456789", 5)
+
+	// a reader that got the manifest just before an overwrite or delete finds the old chunks gone
+	for _, replace := range []func(){
+		func() { assert.Nil(t, c.MSet(ctx, map[string]string{"big": large + "!"})) },
+		func() { assert.Nil(t, c.MDel(ctx, []string{"big"})) },
+	} {
+		assert.Nil(t, c.MSet(ctx, map[string]string{"big": large}))
+		m, ok := parseManifest(mustGet(t, s, "c:big"))
+		assert.True(t, ok)
+		replace()
+
+		data, errs := c.readChunks(ctx, rdb, []chunkRead{{idx: 0, redisKey: "c:big", m: m}})
+		assert.Empty(t, data)
+		assert.Empty(t, errs)
+	}
+	assert.Zero(t, c.CorruptEntries())
+}
+
+func TestChunkedExpiration(t *testing.T) {
+	s, rdb := setupRedis(t)
+	ctx := context.TODO()
+	large := strings.Repeat("0123456789", 5)
+	// chunk keys are derived differently for keys with and without a hash tag
+	for _, prefix := range []string{"c:", "{tenant}:c:"} {
+		c := NewRedisCache[string, string](rdb, time.Minute).SetPrefix(prefix).
+			SetChunking(16, 8).SetSlidingExpiration(30 * time.Second)
+		assert.Nil(t, c.MSet(ctx, map[string]string{"big": large}))
+		m, ok := parseManifest(mustGet(t, s, prefix+"big"))
+		assert.True(t, ok)
+		keys := append(m.chunkKeys(prefix+"big"), prefix+"big")
+
+		// reads below the threshold extend the chunks along with the manifest
+		s.FastForward(40 * time.Second)
+		_, _, err := c.MGet(ctx, []string{"big"})
+		assert.Nil(t, err)
+		for _, key := range keys {
+			assert.Equal(t, time.Minute, s.TTL(key), key)
+		}
+
+		// so does MTouch
+		s.FastForward(10 * time.Second)
+		assert.Nil(t, c.MTouch(ctx, []string{"big", "none"}))
+		for _, key := range keys {
+			assert.Equal(t, time.Minute, s.TTL(key), key)
+		}
+
+		s.FastForward(50 * time.Second)
+		ret, _, err := c.MGet(ctx, []string{"big"})
+		assert.Nil(t, err)
+		assert.Equal(t, map[string]string{"big": large}, ret)
+	}
+}
+
+func TestParseManifest(t *testing.T) {
+	m := manifest{gen: "k3x", n: 2, size: 9, crc: 42}
+	parsed, ok := parseManifest(m.String())
+	assert.True(t, ok)
+	assert.Equal(t, m, parsed)
+
+	// values that merely start with the magic are not manifests
+	for _, value := range []string{chunkMagic, chunkMagic + "x", chunkMagic + "g:2:9", chunkMagic + "g!:2:9:42", chunkMagic + "g:3:2:42"} {
+		_, ok = parseManifest(value)
+		assert.False(t, ok, value)
+	}
+}
+
+func TestChunkKeysShareSlot(t *testing.T) {
+	m := manifest{gen: "g", n: 2}
+	for _, key := range []string{"c:plain", "c:{user1}:7"} {
+		for _, chunk := range m.chunkKeys(key) {
+			assert.Equal(t, hashSlot(key), hashSlot(chunk))
+		}
+	}
+}
+
+func TestKeyEncoder(t *testing.T) {
+	s, rdb := setupRedis(t)
+	ctx := context.TODO()
+	c := NewRedisCache[int64, string](rdb, time.Hour).SetPrefix("k:").SetKeyEncoder(codec.IntKey[int64]{})
+	assert.Nil(t, c.MSet(ctx, map[int64]string{-7: "a"}))
+	assert.Equal(t, []string{"k:-7"}, s.Keys())
+
+	k, err := c.DecodeKey("k:-7")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(-7), k)
+	_, err = c.DecodeKey("other:-7")
+	assert.ErrorIs(t, err, codec.ErrKeyNotDecodable)
+
+	c.SetHashTag(func(k int64) string { return "t" })
+	k, err = c.DecodeKey(c.getRedisKey(12))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(12), k)
+
+	// the default encoder can't decode
+	_, err = NewRedisCache[int64, string](rdb, time.Hour).DecodeKey("1")
+	assert.ErrorIs(t, err, codec.ErrKeyNotDecodable)
+}
+
+func TestDiscardUnknownSchema(t *testing.T) {
+	s, rdb := setupRedis(t)
+	ctx := context.TODO()
+	newer := NewRedisCache[string, string](rdb, time.Hour).SetPrefix("d:").
+		SetCodec(codec.NewSchemaCodec[string](&codec.JsonCodec[string]{}, 2))
+	assert.Nil(t, newer.MSet(ctx, map[string]string{"a": "new"}))
+
+	// unknown versions are misses, and only deleted if the codec asks for it
+	schema := codec.NewSchemaCodec[string](&codec.JsonCodec[string]{}, 1)
+	c := NewRedisCache[string, string](rdb, time.Hour).SetPrefix("d:").SetCodec(schema)
+	_, miss, err := c.MGet(ctx, []string{"a"})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a"}, miss)
+	assert.True(t, s.Exists("d:a"))
+
+	schema.SetDiscardUnknown(true)
+	_, miss, err = c.MGet(ctx, []string{"a"})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a"}, miss)
+	assert.False(t, s.Exists("d:a"))
+
+	// chunked values are deleted with their chunks
+	newer.SetChunking(8, 4)
+	c.SetChunking(8, 4)
+	assert.Nil(t, newer.MSet(ctx, map[string]string{"big": "a long value"}))
+	_, miss, err = c.MGet(ctx, []string{"big"})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"big"}, miss)
+	assert.Empty(t, s.Keys())
+}
+
+func TestEncryptedValues(t *testing.T) {
+	s, rdb := setupRedis(t)
+	ctx := context.TODO()
+	ring, err := codec.NewKeyring(1, map[byte][]byte{1: []byte("0123456789abcdef0123456789abcdef")})
+	assert.Nil(t, err)
+	aesCodec := codec.NewAESCodec[string](&codec.JsonCodec[string]{}, ring).SetBindKey(true)
+	// key binding also works when the AESCodec is wrapped
+	for _, valueCodec := range []codec.Codec[string]{aesCodec, codec.NewChecksumCodec[string](aesCodec, codec.CRC32)} {
+		c := NewRedisCache[string, string](rdb, time.Hour).SetPrefix("e:").SetCodec(valueCodec)
+
+		assert.Nil(t, c.MSet(ctx, map[string]string{"alice": "alice@example.com"}))
+		assert.NotContains(t, mustGet(t, s, "e:alice"), "alice@example.com")
+		ret, _, err := c.MGet(ctx, []string{"alice"})
+		assert.Nil(t, err)
+		assert.Equal(t, map[string]string{"alice": "alice@example.com"}, ret)
+
+		// a value replayed under another key doesn't open, and is deleted
+		assert.Nil(t, s.Set("e:mallory", mustGet(t, s, "e:alice")))
+		_, miss, err := c.MGet(ctx, []string{"mallory"})
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"mallory"}, miss)
+		assert.False(t, s.Exists("e:mallory"))
+	}
+}
+
+func TestCorruptEntries(t *testing.T) {
+	s, rdb := setupRedis(t)
+	ctx := context.TODO()
+	var reported []string
+	c := NewRedisCache[string, string](rdb, time.Hour).SetPrefix("x:").
+		SetCodec(codec.NewChecksumCodec[string](&codec.JsonCodec[string]{}, codec.CRC32)).
+		SetOnCorrupt(func(ctx context.Context, redisKey string, err error) { reported = append(reported, redisKey) })
+
+	// a failed checksum is a miss, and the value is deleted
+	assert.Nil(t, c.MSet(ctx, map[string]string{"a": "value", "b": "value"}))
+	stored := []byte(mustGet(t, s, "x:a"))
+	stored[len(stored)-2] ^= 1
+	assert.Nil(t, s.Set("x:a", string(stored)))
+	ret, miss, err := c.MGet(ctx, []string{"a", "b"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"b": "value"}, ret)
+	assert.Equal(t, []string{"a"}, miss)
+	assert.False(t, s.Exists("x:a"))
+	assert.Equal(t, []string{"x:a"}, reported)
+	assert.Equal(t, uint64(1), c.CorruptEntries())
+
+	// other decode failures are only deleted when asked to
+	assert.Nil(t, s.Set("x:c", "not json"))
+	_, miss, err = c.MGet(ctx, []string{"c"})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"c"}, miss)
+	assert.True(t, s.Exists("x:c"))
+
+	c.SetDropUndecodable(true)
+	_, _, err = c.MGet(ctx, []string{"c"})
+	assert.Nil(t, err)
+	assert.False(t, s.Exists("x:c"))
+	assert.Equal(t, []string{"x:a", "x:c", "x:c"}, reported)
+	assert.Equal(t, uint64(3), c.CorruptEntries())
+}
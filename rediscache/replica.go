@@ -0,0 +1,195 @@
+package rediscache
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"math/rand/v2"
+	"sync/atomic"
+	"time"
+
+	"github.com/maypok86/otter/v2"
+	"github.com/mbeoliero/tiercache/cacher"
+	"github.com/redis/go-redis/v9"
+)
+
+// ReadPolicy chooses the replica that serves a read
+type ReadPolicy int
+
+const (
+	// ReadRandom picks a random replica for every read
+	ReadRandom ReadPolicy = iota
+	// ReadRoundRobin cycles through the replicas
+	ReadRoundRobin
+	// ReadLowestLatency picks the replica with the lowest average read latency,
+	// trying the others now and then so that recovered replicas are noticed
+	ReadLowestLatency
+)
+
+// exploreRate is the share of reads that ReadLowestLatency sends to a random replica
+const exploreRate = 0.05
+
+// SetReadReplicas sends MGet to the replicas, chosen by the policy. Writes, deletes and the
+// version-aware methods keep using the primary client given to NewRedisCache.
+// Keys a replica fails to read are read from the primary.
+func (r *RedisCache[K, V]) SetReadReplicas(policy ReadPolicy, replicas ...redis.UniversalClient) *RedisCache[K, V] {
+	if len(replicas) == 0 {
+		r.replicas = nil
+		return r
+	}
+	pool := &replicaPool{policy: policy}
+	for _, cli := range replicas {
+		pool.replicas = append(pool.replicas, &replica{cli: cli})
+	}
+	r.replicas = pool
+	return r
+}
+
+// SetReadYourWrites reads keys from the primary for the window after this cache wrote or deleted them,
+// so a process doesn't see stale data from a lagging replica right after its own write.
+// The keys are tracked in memory by this RedisCache only; writes of other processes are not seen.
+func (r *RedisCache[K, V]) SetReadYourWrites(window time.Duration) *RedisCache[K, V] {
+	if window <= 0 {
+		r.recentWrites = nil
+		return r
+	}
+	r.recentWrites = otter.Must(&otter.Options[K, struct{}]{
+		MaximumSize:      100_000,
+		ExpiryCalculator: otter.ExpiryWriting[K, struct{}](window),
+	})
+	return r
+}
+
+// markWritten starts the read-your-writes window of the keys
+func (r *RedisCache[K, V]) markWritten(keys iter.Seq[K]) {
+	if r.recentWrites == nil {
+		return
+	}
+	for k := range keys {
+		r.recentWrites.Set(k, struct{}{})
+	}
+}
+
+// mgetReplicas reads the keys from a replica, except the recently written ones and the ones
+// the replica failed to read, which are read from the primary
+func (r *RedisCache[K, V]) mgetReplicas(ctx context.Context, keys []K) (map[K]V, []K, error) {
+	var primaryKeys, replicaKeys []K
+	for _, k := range keys {
+		if r.recentWrites != nil {
+			if _, ok := r.recentWrites.GetIfPresent(k); ok {
+				primaryKeys = append(primaryKeys, k)
+				continue
+			}
+		}
+		replicaKeys = append(replicaKeys, k)
+	}
+
+	ret := make(map[K]V, len(keys))
+	if len(replicaKeys) > 0 {
+		rep := r.replicas.pick()
+		start := time.Now()
+		found, _, err := r.mget(ctx, rep.cli, replicaKeys)
+		var partialErr *cacher.PartialError[K]
+		switch {
+		case err == nil:
+			rep.observe(time.Since(start))
+		case errors.As(err, &partialErr):
+			rep.penalize()
+			for k := range partialErr.Errors {
+				primaryKeys = append(primaryKeys, k)
+			}
+		default:
+			rep.penalize()
+			if r.opt.Logger != nil {
+				r.opt.Logger.CtxError(ctx, "[redis-cache] replica read failed, reading from primary. err=%v", err)
+			}
+			primaryKeys = append(primaryKeys, replicaKeys...)
+		}
+		for k, v := range found {
+			ret[k] = v
+		}
+	}
+
+	var failed map[K]error
+	if len(primaryKeys) > 0 {
+		found, _, err := r.mget(ctx, r.cli, primaryKeys)
+		if err != nil {
+			var partialErr *cacher.PartialError[K]
+			if errors.As(err, &partialErr) {
+				failed = partialErr.Errors
+			} else if len(primaryKeys) == len(keys) {
+				return nil, nil, err
+			} else {
+				failed = make(map[K]error, len(primaryKeys))
+				for _, k := range primaryKeys {
+					failed[k] = err
+				}
+			}
+		}
+		for k, v := range found {
+			ret[k] = v
+		}
+	}
+
+	miss := make([]K, 0)
+	for _, k := range keys {
+		if _, ok := ret[k]; ok {
+			continue
+		}
+		if _, ok := failed[k]; !ok {
+			miss = append(miss, k)
+		}
+	}
+	if len(failed) > 0 {
+		return ret, miss, cacher.NewPartialError(failed)
+	}
+	return ret, miss, nil
+}
+
+type replicaPool struct {
+	policy   ReadPolicy
+	replicas []*replica
+	next     atomic.Uint64
+}
+
+func (p *replicaPool) pick() *replica {
+	n := len(p.replicas)
+	switch p.policy {
+	case ReadRoundRobin:
+		return p.replicas[(p.next.Add(1)-1)%uint64(n)]
+	case ReadLowestLatency:
+		if rand.Float64() < exploreRate {
+			return p.replicas[rand.IntN(n)]
+		}
+		best := p.replicas[0]
+		for _, rep := range p.replicas[1:] {
+			if rep.latency.Load() < best.latency.Load() {
+				best = rep
+			}
+		}
+		return best
+	default:
+		return p.replicas[rand.IntN(n)]
+	}
+}
+
+// replica is a read client with the moving average of its read latency in nanoseconds.
+// Replicas that were never measured have a latency of 0, so they are tried first.
+type replica struct {
+	cli     redis.UniversalClient
+	latency atomic.Int64
+}
+
+func (r *replica) observe(d time.Duration) {
+	old := r.latency.Load()
+	if old == 0 {
+		r.latency.Store(int64(d))
+		return
+	}
+	r.latency.Store(old + (int64(d)-old)/8)
+}
+
+// penalize makes a failing replica unattractive until it is measured again
+func (r *replica) penalize() {
+	r.latency.Store(max(2*r.latency.Load(), int64(time.Second)))
+}
@@ -0,0 +1,96 @@
+package rediscache
+
+import (
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const clusterSlots = 16384
+
+// hashSlot returns the Redis Cluster hash slot of key, honouring {hash tags}
+func hashSlot(key string) int {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			key = key[start+1 : start+1+end]
+		}
+	}
+	return int(crc16(key) % clusterSlots)
+}
+
+// crc16 implements CRC-16/XMODEM as used by Redis Cluster
+func crc16(s string) uint16 {
+	var crc uint16
+	for i := 0; i < len(s); i++ {
+		crc ^= uint16(s[i]) << 8
+		for j := 0; j < 8; j++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+// batches splits the indexes of redisKeys into batches of at most size keys that can be sent to cli as one
+// multi-key command. With a cluster client every batch only holds keys of one hash slot; the cluster
+// pipeline then sends the batches of each node together and the nodes in parallel, following redirects.
+// Other sharding clients, such as a redis.Ring, route a command by its first key only and don't expose
+// their shards, so every key gets a batch of its own.
+func batches(cli redis.UniversalClient, redisKeys []string, size int) [][]int {
+	if size <= 0 {
+		size = len(redisKeys)
+	}
+
+	var groups [][]int
+	switch cli.(type) {
+	case *redis.Client:
+		all := make([]int, len(redisKeys))
+		for i := range all {
+			all[i] = i
+		}
+		groups = [][]int{all}
+	case *redis.ClusterClient:
+		bySlot := make(map[int]int)
+		for i, key := range redisKeys {
+			slot := hashSlot(key)
+			g, ok := bySlot[slot]
+			if !ok {
+				g = len(groups)
+				bySlot[slot] = g
+				groups = append(groups, nil)
+			}
+			groups[g] = append(groups[g], i)
+		}
+	default:
+		ret := make([][]int, len(redisKeys))
+		for i := range ret {
+			ret[i] = []int{i}
+		}
+		return ret
+	}
+
+	ret := make([][]int, 0, len(groups))
+	for _, g := range groups {
+		for start := 0; start < len(g); start += size {
+			ret = append(ret, g[start:min(start+size, len(g))])
+		}
+	}
+	return ret
+}
+
+// batches splits redisKeys into batches for the primary, see batches
+func (r *RedisCache[K, V]) batches(redisKeys []string, size int) [][]int {
+	return batches(r.cli, redisKeys, size)
+}
+
+// pick returns the items at the given indexes
+func pick[T any](items []T, idx []int) []T {
+	ret := make([]T, 0, len(idx))
+	for _, i := range idx {
+		ret = append(ret, items[i])
+	}
+	return ret
+}
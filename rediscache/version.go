@@ -0,0 +1,230 @@
+package rediscache
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+
+	"github.com/mbeoliero/tiercache/codec"
+	"github.com/redis/go-redis/v9"
+)
+
+// Versioned values are stored as "<version>|<encoded value>", so the Lua scripts can compare versions
+// without decoding the value.
+const versionSep = '|'
+
+// setScript stores each value with the TTL in milliseconds given as the first argument
+var setScript = redis.NewScript(`
+local ttl = tonumber(ARGV[1])
+for i, key in ipairs(KEYS) do
+	if ttl > 0 then
+		redis.call('SET', key, ARGV[i + 1], 'PX', ttl)
+	else
+		redis.call('SET', key, ARGV[i + 1])
+	end
+end
+return 1
+`)
+
+// versionedSetScript stores each value with the current version of its key plus one
+var versionedSetScript = redis.NewScript(`
+local ttl = tonumber(ARGV[1])
+for i, key in ipairs(KEYS) do
+	local ver = 0
+	local cur = redis.call('GET', key)
+	if cur then
+		local sep = string.find(cur, '|', 1, true)
+		if sep then ver = tonumber(string.sub(cur, 1, sep - 1)) or 0 end
+	end
+	local val = string.format('%d', ver + 1) .. '|' .. ARGV[i + 1]
+	if ttl > 0 then
+		redis.call('SET', key, val, 'PX', ttl)
+	else
+		redis.call('SET', key, val)
+	end
+end
+return 1
+`)
+
+// compareAndSetScript stores the value only if its version is newer than the stored one
+var compareAndSetScript = redis.NewScript(`
+local cur = redis.call('GET', KEYS[1])
+if cur then
+	local sep = string.find(cur, '|', 1, true)
+	local ver = sep and tonumber(string.sub(cur, 1, sep - 1)) or 0
+	if tonumber(ARGV[1]) <= ver then return 0 end
+end
+local ttl = tonumber(ARGV[2])
+if ttl > 0 then
+	redis.call('SET', KEYS[1], ARGV[1] .. '|' .. ARGV[3], 'PX', ttl)
+else
+	redis.call('SET', KEYS[1], ARGV[1] .. '|' .. ARGV[3])
+end
+return 1
+`)
+
+// compareAndDeleteScript deletes the key only if the stored version is not newer than the given one
+var compareAndDeleteScript = redis.NewScript(`
+local cur = redis.call('GET', KEYS[1])
+if not cur then return 0 end
+local sep = string.find(cur, '|', 1, true)
+local ver = sep and tonumber(string.sub(cur, 1, sep - 1)) or 0
+if ver > tonumber(ARGV[1]) then return 0 end
+return redis.call('DEL', KEYS[1])
+`)
+
+// ErrNotVersioned is returned by the version-aware methods when versioning is not enabled
+var ErrNotVersioned = errors.New("redis-cache: versioning is not enabled")
+
+// SetVersioning makes the cache keep a monotonically increasing version with each value.
+// Every write increments the version of the key, and back-population (see cacher.WithBackfill) only
+// writes keys that don't exist, so a value read from a lower level never replaces a newer one.
+// Lower levels don't report versions, so back-populated values are stored as version 1; use SetVersionOf
+// to keep the version of the source instead. Values written without versioning are read as version 0.
+func (r *RedisCache[K, V]) SetVersioning(enabled bool) *RedisCache[K, V] {
+	r.opt.Versioned = enabled
+	return r
+}
+
+// SetVersionOf sets how the version of a value read from a lower level is found, e.g. from its revision
+// or updated_at column. Back-population then stores values with that version, and only if it is newer than
+// the stored version, so it stays comparable with the versions passed to CompareAndSet.
+func (r *RedisCache[K, V]) SetVersionOf(fn func(V) uint64) *RedisCache[K, V] {
+	r.opt.VersionOf = fn
+	return r
+}
+
+// GetWithVersion reads a single key together with its version
+func (r *RedisCache[K, V]) GetWithVersion(ctx context.Context, key K) (V, uint64, bool, error) {
+	var zero V
+	if err := r.checkVersioned(); err != nil {
+		return zero, 0, false, err
+	}
+	data, err := r.cli.Get(ctx, r.getRedisKey(key)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return zero, 0, false, nil
+	}
+	if err != nil {
+		return zero, 0, false, err
+	}
+	entity, ver, err := r.decodeValue(key, data)
+	if errors.Is(err, codec.ErrDiscard) {
+		return zero, 0, false, nil
+	}
+	if err != nil {
+		return zero, 0, false, err
+	}
+	return entity, ver, true, nil
+}
+
+// CompareAndSet stores the value with the given version only if it is newer than the stored version,
+// e.g. so a delayed consumer of change events can never overwrite newer data. It reports whether the value was stored.
+func (r *RedisCache[K, V]) CompareAndSet(ctx context.Context, key K, value V, version uint64) (bool, error) {
+	if err := r.checkVersioned(); err != nil {
+		return false, err
+	}
+	defer r.markWritten(slices.Values([]K{key}))
+	data, err := r.marshal(key, value)
+	if err != nil {
+		return false, err
+	}
+	n, err := compareAndSetScript.Run(ctx, r.cli, []string{r.getRedisKey(key)},
+		strconv.FormatUint(version, 10), r.ttl.Milliseconds(), data).Int()
+	if err != nil {
+		if r.opt.Logger != nil {
+			r.opt.Logger.CtxError(ctx, "[redis-cache] compare and set failed. key=%v,err=%v", key, err)
+		}
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// CompareAndDelete deletes the key only if the stored version is not newer than the given version.
+// It reports whether the key was deleted.
+func (r *RedisCache[K, V]) CompareAndDelete(ctx context.Context, key K, version uint64) (bool, error) {
+	if err := r.checkVersioned(); err != nil {
+		return false, err
+	}
+	defer r.markWritten(slices.Values([]K{key}))
+	n, err := compareAndDeleteScript.Run(ctx, r.cli, []string{r.getRedisKey(key)}, strconv.FormatUint(version, 10)).Int()
+	if err != nil {
+		if r.opt.Logger != nil {
+			r.opt.Logger.CtxError(ctx, "[redis-cache] compare and delete failed. key=%v,err=%v", key, err)
+		}
+		return false, err
+	}
+	return n == 1, nil
+}
+
+func (r *RedisCache[K, V]) checkVersioned() error {
+	if r.opt.HashBucket != nil {
+		return ErrHashMode
+	}
+	if !r.opt.Versioned {
+		return ErrNotVersioned
+	}
+	return nil
+}
+
+// decodeValue decodes a stored value, splitting off its version when versioning is enabled
+func (r *RedisCache[K, V]) decodeValue(key K, data []byte) (V, uint64, error) {
+	var (
+		entity V
+		ver    uint64
+	)
+	if r.opt.Versioned {
+		if sep := bytes.IndexByte(data, versionSep); sep > 0 {
+			if parsed, err := strconv.ParseUint(string(data[:sep]), 10, 64); err == nil {
+				ver, data = parsed, data[sep+1:]
+			}
+		}
+	}
+	if err := r.unmarshal(key, data, &entity); err != nil {
+		return entity, 0, err
+	}
+	return entity, ver, nil
+}
+
+// encodeValue encodes a value, prefixing it with the version when versioning is enabled
+func (r *RedisCache[K, V]) encodeValue(key K, entity V, ver uint64) ([]byte, error) {
+	data, err := r.marshal(key, entity)
+	if err != nil {
+		return nil, err
+	}
+	if !r.opt.Versioned {
+		return data, nil
+	}
+	return fmt.Appendf(nil, "%d%c%s", ver, versionSep, data), nil
+}
+
+// msetMissing back-populates versioned entities, writing only keys that don't exist yet,
+// or with VersionOf set, only keys whose stored version is older than the entity
+func (r *RedisCache[K, V]) msetMissing(ctx context.Context, entities map[K]V) error {
+	p := r.cli.Pipeline()
+	for key, entity := range entities {
+		if r.opt.VersionOf != nil {
+			data, err := r.marshal(key, entity)
+			if err != nil {
+				return err
+			}
+			compareAndSetScript.Eval(ctx, p, []string{r.getRedisKey(key)},
+				strconv.FormatUint(r.opt.VersionOf(entity), 10), r.ttl.Milliseconds(), data)
+			continue
+		}
+		data, err := r.encodeValue(key, entity, 1)
+		if err != nil {
+			return err
+		}
+		p.SetNX(ctx, r.getRedisKey(key), data, r.ttl)
+	}
+	if _, err := p.Exec(ctx); err != nil {
+		if r.opt.Logger != nil {
+			r.opt.Logger.CtxError(ctx, "[redis-cache] MSet back-population failed. err=%v", err)
+		}
+		return err
+	}
+	return nil
+}
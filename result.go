@@ -0,0 +1,46 @@
+package tiercache
+
+import "fmt"
+
+// LevelError describes the failure of a single cache level during a read.
+type LevelError struct {
+	// Level is the 1-based level of the failed store
+	Level int
+	Name  string
+	Err   error
+}
+
+func (e LevelError) Error() string {
+	return fmt.Sprintf("cache store level[%d] name[%s] MGet error: %s", e.Level, e.Name, e.Err)
+}
+
+func (e LevelError) Unwrap() error {
+	return e.Err
+}
+
+// MGetResult is the detailed outcome of MGetDetailed.
+// Every requested key is in exactly one of Found, Missing or Errors.
+// Keys that a store reported through a *cacher.PartialError are in Errors when they were not recovered by falling back.
+type MGetResult[K comparable, V any] struct {
+	// Found holds the values that were found, including those found before a deeper level failed
+	Found map[K]V
+	// Missing holds the keys that no level has
+	Missing []K
+	// Errors holds the keys that could not be resolved because a level failed, with that level's error
+	Errors map[K]error
+	// LevelErrors lists every level failure, including the ones that were recovered by falling back
+	LevelErrors []LevelError
+}
+
+func (r *MGetResult[K, V]) addLevelError(level int, name string, err error) {
+	r.LevelErrors = append(r.LevelErrors, LevelError{Level: level, Name: name, Err: err})
+}
+
+func (r *MGetResult[K, V]) addKeyErrors(keys []K, err error) {
+	if r.Errors == nil {
+		r.Errors = make(map[K]error, len(keys))
+	}
+	for _, k := range keys {
+		r.Errors[k] = err
+	}
+}
@@ -0,0 +1,44 @@
+package tiercache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/mbeoliero/tiercache/cacher"
+)
+
+// Touch extends the expiration of the keys in every cache level implementing cacher.Toucher,
+// without reading or rewriting the values. Loader levels and the levels excluded by the options are skipped.
+// All levels are touched even if one fails; the errors are joined.
+func (c *MultiLevelCache[K, V]) Touch(ctx context.Context, keys []K, opts ...OptFunc) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	o := defaultOpts()
+	for _, opt := range opts {
+		opt(o)
+	}
+	o.skipLoaders = true
+	defer optionsPool.Put(o)
+
+	var errs []error
+	for i, store := range c.stores {
+		mwCtx := cacher.NewContext(ctx, cacher.NewRunInfo(i+1))
+		if c.skipLayer(mwCtx, store, o) {
+			continue
+		}
+		toucher, ok := cacher.As[cacher.Toucher[K]](store)
+		if !ok {
+			continue
+		}
+
+		layerCtx, cancel := c.layerContext(mwCtx, store, o)
+		err := toucher.MTouch(layerCtx, keys)
+		cancel()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("cache store idx[%d] Touch error: %w", i, err))
+		}
+	}
+	return errors.Join(errs...)
+}